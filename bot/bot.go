@@ -9,41 +9,21 @@ import (
 	"time"
 )
 
-const numWorkers = 16
-
-type eventWorker struct {
-	id      int
-	eventCh chan *Event
-	quitCh  chan bool
-	wg      *sync.WaitGroup
-}
-
-func (w *eventWorker) start(bot *Bot) {
-	for {
-		select {
-		case event := <-w.eventCh:
-			if event == nil {
-				continue
-			}
-			bot.handleEvent(event)
-			break
-		case <-w.quitCh:
-			w.wg.Done()
-			return
-		}
-	}
-}
-
 type Bot struct {
 	BaseModule
 
-	config   *BotConfig
-	stdin    *Stdin
-	engine   *CommandEngine
-	modules  []Module
-	handlers map[EventType]EventHandlers
-	start    time.Time
-	eventQ   chan *Event
+	config  *BotConfig
+	stdin   *Stdin
+	engine  *CommandEngine
+	perms   *Permissions
+	crashes *crashes
+	modules []Module
+	addons  []Module
+	running map[Module]*moduleRegs
+	modLock sync.Mutex
+	bus     *eventBus
+	lanes   *eventLanes
+	start   time.Time
 }
 
 func NewBot(name string, config *BotConfig) *Bot {
@@ -53,16 +33,20 @@ func NewBot(name string, config *BotConfig) *Bot {
 	bot.start = time.Now()
 	bot.Name = name
 	bot.config = config
-	bot.eventQ = make(chan *Event)
 	bot.exitCh = make(chan bool)
 	bot.Logger = NewLoggerFunc(fmt.Sprintf("%s/%s-bot",
 		bot.config.LogDir, bot.Name))
-	bot.handlers = make(map[EventType]EventHandlers)
+	bot.bus = newEventBus()
+	bot.lanes = newEventLanes(bot, config.GetEventQueue())
 
 	// basic handles that keep the bot work
-	bot.RegisterEventHandler(Input, bot.handleInput)
-	bot.RegisterEventHandler(PrivateMessage, bot.handlePrivateMessage)
-	bot.RegisterEventHandler(ChannelMessage, bot.handleChannelMessage)
+	bot.Subscribe(OnInput(bot.handleInput, Normal))
+	bot.Subscribe(OnPrivateMessage(bot.handlePrivateMessage, Normal))
+	bot.Subscribe(OnChannelMessage(bot.handleChannelMessage, Normal))
+
+	bot.perms = bot.newPermissions()
+	bot.crashes = newCrashes()
+	bot.running = make(map[Module]*moduleRegs)
 
 	bot.stdin = NewStdin(bot)
 	bot.engine = NewCommandEngine(bot)
@@ -79,6 +63,7 @@ func NewBot(name string, config *BotConfig) *Bot {
 		mod := f(bot)
 		if mod != nil {
 			bot.modules = append(bot.modules, mod)
+			bot.addons = append(bot.addons, mod)
 		}
 	}
 
@@ -97,6 +82,10 @@ func (bot *Bot) Start() {
 
 	var mod Module
 	for _, mod = range bot.modules {
+		if bot.isAddon(mod) {
+			bot.startAddon(mod)
+			continue
+		}
 		err = mod.Init()
 		if err != nil {
 			bot.Logger.Printf("module %s init failed: %s", mod, err)
@@ -115,20 +104,31 @@ func (bot *Bot) Start() {
 	bot.loop()
 }
 
-func (bot *Bot) loop() {
-	var quit chan bool = make(chan bool)
-
-	for i := 0; i < numWorkers; i++ {
-		bot.wait.Add(1)
-		w := &eventWorker{i, bot.eventQ, quit, &bot.wait}
-		go w.start(bot)
+func (bot *Bot) isAddon(mod Module) bool {
+	for _, m := range bot.addons {
+		if m == mod {
+			return true
+		}
 	}
+	return false
+}
 
-	exit := <-bot.exitCh
-	if exit {
-		close(quit)
+// startAddon starts the addon module unless disabled in the config,
+// a failed addon is left stopped.
+func (bot *Bot) startAddon(mod Module) {
+	if !bot.config.ModuleEnabled(fmt.Sprint(mod)) {
+		bot.Logger.Printf("Module %s disabled", mod)
+		return
+	}
+	if err := bot.startModule(mod); err != nil {
+		bot.Logger.Printf("module %s start failed: %s", mod, err)
+		return
 	}
+	bot.Logger.Printf("Module %s running", mod)
+}
 
+func (bot *Bot) loop() {
+	<-bot.exitCh
 	bot.Logger.Print("Bot exiting")
 }
 
@@ -137,53 +137,67 @@ func (bot *Bot) Stop() {
 	var mod Module
 	bot.Logger.Printf("bot %s stopping", bot.Name)
 	for _, mod = range bot.modules {
-		err = mod.Stop()
+		if !bot.isAddon(mod) {
+			err = mod.Stop()
+		} else if bot.isRunning(mod) {
+			err = bot.stopModule(mod)
+		} else {
+			continue
+		}
 		if err != nil {
 			bot.Logger.Printf("module %s stop failed: %s", mod, err)
 		} else {
 			bot.Logger.Printf("module %s stopped", mod)
 		}
 	}
+	bot.perms.Close()
 	bot.State = Stopped
 	bot.exitCh <- true
-	bot.wait.Wait()
+	bot.lanes.stop()
 }
 
-// events
-func (bot *Bot) AddEvent(event *Event) {
-	bot.eventQ <- event
-}
+// newPermissions loads the permissions from the database,
+// they are kept only in memory if it is not configured or fails to open.
+func (bot *Bot) newPermissions() *Permissions {
+	var dbpath string
 
-func (bot *Bot) RegisterEventHandler(evt EventType, h EventHandler) {
-	if _, ok := bot.handlers[evt]; !ok {
-		bot.handlers[evt] = &[2][]EventHandler{}
+	if bot.config.DataDir != "" && bot.config.DB != "" {
+		dbpath = bot.config.DataDir + "/" + bot.config.DB
 	}
-	bot.handlers[evt][High] = append(bot.handlers[evt][High], h)
+	perms, err := NewPermissions(dbpath, bot.config.Owners)
+	if err != nil {
+		bot.Logger.Printf("Failed to load permissions: %s", err)
+		perms, _ = NewPermissions("", bot.config.Owners)
+	}
+	return perms
 }
 
-func (bot *Bot) RegisterEventHandlerPrio(evt EventType, h EventHandler, p Priority) {
-	if _, ok := bot.handlers[evt]; !ok {
-		bot.handlers[evt] = &[2][]EventHandler{}
-	}
-	bot.handlers[evt][p] = append(bot.handlers[evt][p], h)
+// events
+// AddEvent queues the event to the lane of its network and channel.
+func (bot *Bot) AddEvent(event *Event) {
+	bot.lanes.dispatch(event)
+}
+
+// Subscribe registers the handler made by OnChannelMessage and the like,
+// handlers with higher priority are called first.
+func (bot *Bot) Subscribe(h Handler) *Subscription {
+	return bot.bus.subscribe(h.evt, h.fn, h.prio)
 }
 
 func (bot *Bot) handleEvent(event *Event) {
-	var hs EventHandlers
-	var h EventHandler
+	var subs []*subscriber
+	var ctx *EventContext
 
-	hs, ok := bot.handlers[event.evt]
-	if !ok {
-		bot.Logger.Println("Unknown event type:", event.evt)
-		return
-	}
-	if hs == nil {
+	subs = bot.bus.subscribers(event.evt)
+	if len(subs) == 0 {
 		bot.Logger.Printf("%s ignored", event.evt)
 		return
 	}
-	for _, phs := range hs {
-		for _, h = range phs {
-			h(event.data)
+	ctx = &EventContext{Event: event}
+	for _, s := range subs {
+		bot.callHandler(s, ctx)
+		if ctx.stopped {
+			break
 		}
 	}
 }
@@ -191,16 +205,12 @@ func (bot *Bot) handleEvent(event *Event) {
 // end events
 
 // event handlers
-func (bot *Bot) handleInput(data interface{}) {
-	var input string
-
-	bot.Logger.Println(data)
-	input = data.(string)
+func (bot *Bot) handleInput(ctx *EventContext, input string) {
+	bot.Logger.Println(input)
 	bot.engine.Submit(input)
 }
 
-func (bot *Bot) handlePrivateMessage(data interface{}) {
-	privMsgData := data.(*PrivateMessageData)
+func (bot *Bot) handlePrivateMessage(ctx *EventContext, privMsgData *PrivateMessageData) {
 	text := privMsgData.text
 	trigger := privMsgData.irc.config.GetTrigger("")
 
@@ -220,8 +230,7 @@ func (bot *Bot) handlePrivateMessage(data interface{}) {
 	req.irc.interpreter.Submit(&req)
 }
 
-func (bot *Bot) handleChannelMessage(data interface{}) {
-	chanMsgData := data.(*ChannelMessageData)
+func (bot *Bot) handleChannelMessage(ctx *EventContext, chanMsgData *ChannelMessageData) {
 
 	req := MessageRequest{
 		irc:     chanMsgData.irc,
@@ -49,10 +49,10 @@ func TestBotEvent(t *testing.T) {
 
 	//new event
 	var hit bool
-	bot.RegisterEventHandler(evt, func(interface{}) {
+	bot.Subscribe(OnEvent(evt, func(*EventContext, interface{}) {
 		hit = true
 		t.Log("event handled")
-	})
+	}, Normal))
 	bot.AddEvent(NewEvent(evt, nil))
 
 	//exit
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"sync"
 )
 
 type Empty struct{}
@@ -17,6 +18,7 @@ const (
 )
 
 type Channel struct {
+	sync.RWMutex
 	irc *IRC
 
 	name string
@@ -25,11 +27,14 @@ type Channel struct {
 
 	url string
 
-	mode byte
+	// channel type from NAMES reply, = public, * private, @ secret
+	visibility byte
+	// modes with their parameters and list modes like bans
+	modes map[byte]string
+	lists map[byte][]string
 
-	users  map[string]Empty
-	nop    int
-	nvoice int
+	// users keyed by the nick folded with the server casemapping
+	users map[string]*ChannelUser
 
 	logger *log.Logger
 }
@@ -42,24 +47,21 @@ func NewChannel(irc *IRC, name string) *Channel {
 		fmt.Sprintf("%s/%s-%s-%s",
 			irc.bot.config.LogDir, irc.bot.Name, irc.Name, name))
 	ch.logger.SetFlags(log.LstdFlags)
-	ch.users = make(map[string]Empty)
+	ch.users = make(map[string]*ChannelUser)
+	ch.modes = make(map[byte]string)
+	ch.lists = make(map[byte][]string)
 
 	return ch
 }
 
 func (ch *Channel) String() string {
 	var nicks []string
-	nicks = make([]string, 0, len(ch.users))
-	i := 0
-	for nick := range ch.users {
-		nicks = append(nicks, nick)
-		i++
-		if i > 5 {
-			nicks = append(nicks, "...")
-			break
-		}
+	nicks = ch.Users()
+	n := len(nicks)
+	if n > 6 {
+		nicks = append(nicks[:6], "...")
 	}
-	return fmt.Sprintf("%s %d%s", ch.name, len(ch.users), nicks)
+	return fmt.Sprintf("%s %d%s", ch.name, n, nicks)
 }
 
 func (ch *Channel) Log(dir, format string, param ...interface{}) {
@@ -72,7 +74,9 @@ func (ch *Channel) Start(me string) {
 }
 
 func (ch *Channel) Stop() {
-	ch.users = make(map[string]Empty)
+	ch.Lock()
+	ch.users = make(map[string]*ChannelUser)
+	ch.Unlock()
 }
 
 func (ch *Channel) Topic() string {
@@ -85,16 +89,39 @@ func (ch *Channel) SetTopic(topic string) {
 
 // user management
 func (ch *Channel) add(nick string) {
-	ch.users[nick] = Empty{}
+	ch.Lock()
+	defer ch.Unlock()
+	key := ch.irc.isupport.Fold(nick)
+	if _, ok := ch.users[key]; !ok {
+		ch.users[key] = &ChannelUser{Nick: nick}
+	}
 }
 
 func (ch *Channel) contains(nick string) bool {
-	_, ok := ch.users[nick]
+	ch.RLock()
+	defer ch.RUnlock()
+	_, ok := ch.users[ch.irc.isupport.Fold(nick)]
 	return ok
 }
 
 func (ch *Channel) remove(nick string) {
-	delete(ch.users, nick)
+	ch.Lock()
+	delete(ch.users, ch.irc.isupport.Fold(nick))
+	ch.Unlock()
+}
+
+// rename keeps the modes of the user changing nick.
+func (ch *Channel) rename(nick, newNick string) {
+	ch.Lock()
+	defer ch.Unlock()
+	key := ch.irc.isupport.Fold(nick)
+	user, ok := ch.users[key]
+	if !ok {
+		return
+	}
+	delete(ch.users, key)
+	user.Nick = newNick
+	ch.users[ch.irc.isupport.Fold(newNick)] = user
 }
 
 // command handlers
@@ -102,33 +129,13 @@ func (ch *Channel) onPrivmsg(from, msg string) {
 	ch.logger.Printf("<%s>\t%s", from, msg)
 }
 
-func (ch *Channel) onJoin(from string) {
-	var m []string
-	var nick string
-
-	m = nickRe.FindStringSubmatch(from)
-	if m != nil {
-		nick = m[1]
-	} else {
-		panic(from)
-	}
-
+func (ch *Channel) onJoin(nick, from string) {
 	ch.add(nick)
 	ch.Log(IN, "%s (%s) has joined %s",
 		nick, from, ch.name)
 }
 
-func (ch *Channel) onPart(from, msg string) {
-	var m []string
-	var nick string
-
-	m = nickRe.FindStringSubmatch(from)
-	if m != nil {
-		nick = m[1]
-	} else {
-		panic(from)
-	}
-
+func (ch *Channel) onPart(nick, from, msg string) {
 	ch.remove(nick)
 
 	if msg != "" {
@@ -140,6 +147,12 @@ func (ch *Channel) onPart(from, msg string) {
 	}
 }
 
+func (ch *Channel) onKick(kicker, victim, reason string) {
+	ch.remove(victim)
+	ch.Log(OUT, "%s was kicked from %s by %s (%s)",
+		victim, ch.name, kicker, reason)
+}
+
 func (ch *Channel) onQuit(nick, from, msg string) {
 	if !ch.contains(nick) {
 		return
@@ -148,7 +161,7 @@ func (ch *Channel) onQuit(nick, from, msg string) {
 	ch.remove(nick)
 	if msg != "" {
 		ch.Log(OUT, "%s (%s) has quit (%s)",
-			nick, from, msg[1:])
+			nick, from, msg)
 	} else {
 		ch.Log(OUT, "%s (%s) has quit",
 			nick, from)
@@ -159,14 +172,23 @@ func (ch *Channel) onNick(nick, newNick string) {
 	if !ch.contains(nick) {
 		return
 	}
-	ch.remove(nick)
-	ch.add(newNick)
+	ch.rename(nick, newNick)
 	ch.Log(NOM, "%s is now known as %s", nick, newNick)
 }
 
-func (ch *Channel) onMode(mode, from string) {
-	nick, _, _ := matchNickUserHost(from)
-	ch.Log(NOM, "Mode %s [%s] by %s", ch.name, mode, nick)
+func (ch *Channel) onMode(nick, modes string, params []string) {
+	var changes []ModeChange
+
+	prefixModes, _ := ch.irc.isupport.Prefix()
+	changes = ch.irc.isupport.parseModes(modes, params)
+	for _, c := range changes {
+		if strings.IndexByte(prefixModes, c.Mode) != -1 {
+			ch.changeMode(c.Param, c.Mode, c.Add)
+		}
+	}
+	ch.applyModes(changes)
+	ch.Log(NOM, "Mode %s [%s] by %s", ch.name,
+		strings.TrimSpace(modes+" "+strings.Join(params, " ")), nick)
 }
 
 func (ch *Channel) onRPL_CHANNELURL(url string) {
@@ -188,27 +210,43 @@ func (ch *Channel) onRPL_TOPICWHOTIME(who, time string) {
 func (ch *Channel) onRPL_NAMREPLY(nicks string) {
 	var arr []string
 
-	arr = strings.Split(nicks, " ")
+	arr = strings.Fields(nicks)
 	for _, nick := range arr {
-		switch nick[0] {
-		default:
-			ch.add(nick)
-		case '@':
-			ch.add(nick[1:])
-			ch.nop++
-		case '+':
-			ch.add(nick[1:])
-			ch.nvoice++
+		// with multi-prefix a nick can have several prefixes
+		prefixes, nick := ch.irc.isupport.TrimPrefix(nick)
+		ch.setModes(nick, ch.prefixModes(prefixes))
+	}
+}
+
+// prefixModes maps the nick prefixes in NAMES reply to modes, e.g. @ to o.
+func (ch *Channel) prefixModes(prefixes string) string {
+	var modes []byte
+
+	prefixModes, prefixChars := ch.irc.isupport.Prefix()
+	for i := 0; i < len(prefixes); i++ {
+		if j := strings.IndexByte(prefixChars, prefixes[i]); j != -1 {
+			modes = append(modes, prefixModes[j])
 		}
 	}
+	return string(modes)
 }
 
 func (ch *Channel) onRPL_ENDOFNAMES() {
+	var nop, nvoice int
+
+	nicks := ch.Users()
+	for _, nick := range nicks {
+		if ch.IsOp(nick) {
+			nop++
+		} else if ch.IsVoice(nick) {
+			nvoice++
+		}
+	}
 	ch.Log(NOM, "Channel %s: %d nicks (%d op, %d voices, %d normals)",
 		ch.name,
-		len(ch.users),
-		ch.nop,
-		ch.nvoice,
-		len(ch.users)-ch.nop-ch.nvoice,
+		len(nicks),
+		nop,
+		nvoice,
+		len(nicks)-nop-nvoice,
 	)
 }
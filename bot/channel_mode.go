@@ -0,0 +1,170 @@
+// Copyright 2016 Alex Fluter
+
+package bot
+
+import (
+	"sort"
+	"strings"
+)
+
+// ModeChange is a single mode set or unset by a MODE message.
+type ModeChange struct {
+	Add   bool
+	Mode  byte
+	Param string
+}
+
+func (c ModeChange) String() string {
+	var s string
+	if c.Add {
+		s = "+" + string(c.Mode)
+	} else {
+		s = "-" + string(c.Mode)
+	}
+	if c.Param != "" {
+		s += " " + c.Param
+	}
+	return s
+}
+
+// parseModes splits a channel mode string with its parameters into
+// changes, the modes taking a parameter are known from PREFIX and CHANMODES.
+func (s *ISupport) parseModes(modes string, params []string) []ModeChange {
+	var changes []ModeChange
+	var add = true
+
+	prefixModes, _ := s.Prefix()
+	groups := s.ChanModes()
+	for i := 0; i < len(modes); i++ {
+		var c = ModeChange{Add: add, Mode: modes[i]}
+		var hasParam bool
+
+		switch {
+		case c.Mode == '+':
+			add = true
+			continue
+		case c.Mode == '-':
+			add = false
+			continue
+		case strings.IndexByte(prefixModes, c.Mode) != -1,
+			strings.IndexByte(groups[0], c.Mode) != -1,
+			strings.IndexByte(groups[1], c.Mode) != -1:
+			hasParam = true
+		case strings.IndexByte(groups[2], c.Mode) != -1:
+			// parameter only when set
+			hasParam = add
+		}
+		if hasParam && len(params) > 0 {
+			c.Param, params = params[0], params[1:]
+		}
+		changes = append(changes, c)
+	}
+	return changes
+}
+
+// applyModes updates the channel modes and lists,
+// membership modes are handled by onMode.
+func (ch *Channel) applyModes(changes []ModeChange) {
+	groups := ch.irc.isupport.ChanModes()
+	prefixModes, _ := ch.irc.isupport.Prefix()
+
+	ch.Lock()
+	defer ch.Unlock()
+	for _, c := range changes {
+		switch {
+		case strings.IndexByte(prefixModes, c.Mode) != -1:
+			continue
+		case strings.IndexByte(groups[0], c.Mode) != -1:
+			if c.Param == "" {
+				// a list query, not a change
+				continue
+			}
+			ch.delListEntry(c.Mode, c.Param)
+			if c.Add {
+				ch.lists[c.Mode] = append(ch.lists[c.Mode], c.Param)
+			}
+		case c.Add:
+			ch.modes[c.Mode] = c.Param
+		default:
+			delete(ch.modes, c.Mode)
+		}
+	}
+}
+
+func (ch *Channel) delListEntry(mode byte, mask string) {
+	list := ch.lists[mode]
+	for i, m := range list {
+		if ch.irc.isupport.Equal(m, mask) {
+			ch.lists[mode] = append(list[:i:i], list[i+1:]...)
+			return
+		}
+	}
+}
+
+// Modes returns the channel modes in the form of +ntk key.
+func (ch *Channel) Modes() string {
+	var flags []byte
+	var params []string
+
+	ch.RLock()
+	for m := range ch.modes {
+		flags = append(flags, m)
+	}
+	sort.Slice(flags, func(i, j int) bool { return flags[i] < flags[j] })
+	for _, m := range flags {
+		if p := ch.modes[m]; p != "" {
+			params = append(params, p)
+		}
+	}
+	ch.RUnlock()
+	return strings.TrimSpace("+" + string(flags) + " " + strings.Join(params, " "))
+}
+
+// Mode returns the parameter of the mode and whether it is set.
+func (ch *Channel) Mode(mode byte) (string, bool) {
+	ch.RLock()
+	defer ch.RUnlock()
+	param, ok := ch.modes[mode]
+	return param, ok
+}
+
+// Key returns the channel key, empty if not +k.
+func (ch *Channel) Key() string {
+	key, _ := ch.Mode('k')
+	return key
+}
+
+// List returns the masks of a list mode like b, e or I.
+func (ch *Channel) List(mode byte) []string {
+	ch.RLock()
+	defer ch.RUnlock()
+	return append([]string(nil), ch.lists[mode]...)
+}
+
+func (ch *Channel) Bans() []string {
+	return ch.List('b')
+}
+
+// requestModes queries the modes and the ban list of the channel.
+func (ch *Channel) requestModes() {
+	ch.Lock()
+	delete(ch.lists, 'b')
+	ch.Unlock()
+	ch.irc.sendMsg("MODE " + ch.name)
+	ch.irc.sendMsg("MODE " + ch.name + " +b")
+}
+
+func (ch *Channel) onRPL_CHANNELMODEIS(modes string, params []string) {
+	ch.Lock()
+	ch.modes = make(map[byte]string)
+	ch.Unlock()
+	ch.applyModes(ch.irc.isupport.parseModes(modes, params))
+	ch.Log(NOM, "Mode %s %s", ch.name, ch.Modes())
+}
+
+func (ch *Channel) onListEntry(mode byte, mask string) {
+	ch.Lock()
+	ch.delListEntry(mode, mask)
+	ch.lists[mode] = append(ch.lists[mode], mask)
+	ch.Unlock()
+}
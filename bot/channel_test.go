@@ -0,0 +1,107 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseModes(t *testing.T) {
+	s := NewISupport()
+	s.parse([]string{"PREFIX=(ov)@+", "CHANMODES=beI,k,l,imnpst"})
+
+	changes := s.parseModes("+kl-l+bo-m", []string{"key", "10", "*!*@host", "foo"})
+	expected := []string{"+k key", "+l 10", "-l", "+b *!*@host", "+o foo", "-m"}
+	if len(changes) != len(expected) {
+		t.Fatal(changes)
+	}
+	for i, c := range changes {
+		if c.String() != expected[i] {
+			t.Error(i, c)
+		}
+	}
+}
+
+func TestChannelUserModes(t *testing.T) {
+	ch := make(chan bool)
+	bot := newTestBot(ch)
+
+	irc := testIRC(bot, t)
+	irc.isupport.parse([]string{"PREFIX=(qaohv)~&@%+"})
+
+	irc.onCommand(command("JOIN", G+"!~u@host", "#candice"))
+	irc.onCommand(command("RPL_NAMREPLY", "server", G+" = #candice :~@fluter +foo bar "+G))
+	channel := irc.GetChannel("#candice")
+	if channel == nil {
+		t.Fatal()
+	}
+	if user, _ := channel.User("fluter"); user.Modes != "qo" || !channel.IsOp("fluter") {
+		t.Error(user)
+	}
+	if !channel.IsVoice("foo") || channel.IsHalfOp("foo") || channel.IsVoice("bar") {
+		t.Error(channel.User("foo"))
+	}
+
+	irc.onCommand(command("MODE", "fluter!~u@host", "#candice +oh-v foo foo foo"))
+	if user, _ := channel.User("foo"); user.Modes != "oh" || !channel.IsOp("foo") {
+		t.Error(user)
+	}
+	irc.onCommand(command("MODE", "fluter!~u@host", "#candice -o foo"))
+	if channel.IsOp("foo") || !channel.IsHalfOp("foo") {
+		t.Error(channel.User("foo"))
+	}
+
+	irc.onCommand(command("NICK", "foo!~u@host", "baz"))
+	if !channel.IsHalfOp("baz") || channel.contains("foo") {
+		t.Error(channel.User("baz"))
+	}
+	irc.onCommand(command("PART", "baz!~u@host", "#candice"))
+	if channel.IsHalfOp("baz") {
+		t.Error(channel.User("baz"))
+	}
+
+	delTestBot(bot, t, ch)
+}
+
+func TestChannelModes(t *testing.T) {
+	ch := make(chan bool)
+	bot := newTestBot(ch)
+
+	irc := testIRC(bot, t)
+	r := testConn(irc)
+	irc.isupport.parse([]string{"PREFIX=(ov)@+", "CHANMODES=beI,k,l,imnpst"})
+
+	go irc.onCommand(command("JOIN", G+"!~u@host", "#candice"))
+	if s := readLine(r); s != "MODE #candice\r\n" {
+		t.Error(s)
+	}
+	if s := readLine(r); s != "MODE #candice +b\r\n" {
+		t.Error(s)
+	}
+	irc.conn = nil
+
+	irc.onCommand(command("RPL_CHANNELMODEIS", "server", G+" #candice +ntkl secret 10"))
+	irc.onCommand(command("RPL_BANLIST", "server", G+" #candice *!*@spam fluter 1469948036"))
+	irc.onCommand(command("RPL_BANLIST", "server", G+" #candice *!*@junk fluter 1469948036"))
+	irc.onCommand(command("RPL_ENDOFBANLIST", "server", G+" #candice :End of Channel Ban List"))
+
+	c := irc.GetChannel("#candice")
+	if c.Modes() != "+klnt secret 10" || c.Key() != "secret" {
+		t.Error(c.Modes())
+	}
+	if bans := c.Bans(); strings.Join(bans, " ") != "*!*@spam *!*@junk" {
+		t.Error(bans)
+	}
+
+	irc.onCommand(command("MODE", "fluter!~u@host", "#candice -lk+mb-b secret *!*@evil *!*@spam"))
+	if _, ok := c.Mode('l'); ok || c.Key() != "" {
+		t.Error(c.Modes())
+	}
+	if _, ok := c.Mode('m'); !ok {
+		t.Error(c.Modes())
+	}
+	if bans := c.Bans(); strings.Join(bans, " ") != "*!*@junk *!*@evil" {
+		t.Error(bans)
+	}
+
+	delTestBot(bot, t, ch)
+}
@@ -0,0 +1,116 @@
+// Copyright 2016 Alex Fluter
+
+package bot
+
+import (
+	"sort"
+	"strings"
+)
+
+// ChannelUser is a member of a channel with the membership modes,
+// like o for op and v for voice, in the order of PREFIX.
+type ChannelUser struct {
+	Nick  string
+	Modes string
+}
+
+// sortModes orders the membership modes by rank.
+func (ch *Channel) sortModes(modes string) string {
+	var b []byte
+
+	prefixModes, _ := ch.irc.isupport.Prefix()
+	for i := 0; i < len(prefixModes); i++ {
+		if strings.IndexByte(modes, prefixModes[i]) != -1 {
+			b = append(b, prefixModes[i])
+		}
+	}
+	return string(b)
+}
+
+// setModes replaces the membership modes of nick, adding it if not present.
+func (ch *Channel) setModes(nick, modes string) {
+	ch.Lock()
+	defer ch.Unlock()
+	key := ch.irc.isupport.Fold(nick)
+	user, ok := ch.users[key]
+	if !ok {
+		user = &ChannelUser{Nick: nick}
+		ch.users[key] = user
+	}
+	user.Modes = ch.sortModes(modes)
+}
+
+// changeMode sets or unsets a membership mode of nick.
+func (ch *Channel) changeMode(nick string, mode byte, add bool) {
+	ch.Lock()
+	defer ch.Unlock()
+	user, ok := ch.users[ch.irc.isupport.Fold(nick)]
+	if !ok {
+		return
+	}
+	i := strings.IndexByte(user.Modes, mode)
+	if add && i == -1 {
+		user.Modes = ch.sortModes(user.Modes + string(mode))
+	} else if !add && i != -1 {
+		user.Modes = user.Modes[:i] + user.Modes[i+1:]
+	}
+}
+
+// User returns the member with nick.
+func (ch *Channel) User(nick string) (ChannelUser, bool) {
+	ch.RLock()
+	defer ch.RUnlock()
+	user, ok := ch.users[ch.irc.isupport.Fold(nick)]
+	if !ok {
+		return ChannelUser{}, false
+	}
+	return *user, true
+}
+
+// Users returns the nicks in the channel.
+func (ch *Channel) Users() []string {
+	var nicks []string
+
+	ch.RLock()
+	for _, user := range ch.users {
+		nicks = append(nicks, user.Nick)
+	}
+	ch.RUnlock()
+	sort.Strings(nicks)
+	return nicks
+}
+
+// HasMode returns true if nick has the membership mode or a higher one,
+// modes not supported by the server are never set.
+func (ch *Channel) HasMode(nick string, mode byte) bool {
+	prefixModes, _ := ch.irc.isupport.Prefix()
+	rank := strings.IndexByte(prefixModes, mode)
+	if rank == -1 {
+		return false
+	}
+	user, ok := ch.User(nick)
+	if !ok {
+		return false
+	}
+	for i := 0; i < len(user.Modes); i++ {
+		if r := strings.IndexByte(prefixModes, user.Modes[i]); r != -1 && r <= rank {
+			return true
+		}
+	}
+	return false
+}
+
+// IsOp returns true if nick is a channel operator or above.
+func (ch *Channel) IsOp(nick string) bool {
+	return ch.HasMode(nick, 'o')
+}
+
+// IsHalfOp returns true if nick is a half operator or above.
+func (ch *Channel) IsHalfOp(nick string) bool {
+	return ch.HasMode(nick, 'h') || ch.IsOp(nick)
+}
+
+// IsVoice returns true if nick is voiced or above.
+func (ch *Channel) IsVoice(nick string) bool {
+	return ch.HasMode(nick, 'v') || ch.IsHalfOp(nick)
+}
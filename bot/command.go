@@ -36,9 +36,12 @@ func (req *MessageRequest) String() string {
 	}
 }
 
-func (req *MessageRequest) cleanURL() {
-	req.url = ""
-	req.neturl = nil
+// target returns where the replies to the request go.
+func (req *MessageRequest) target() string {
+	if req.ischan {
+		return req.channel
+	}
+	return req.nick
 }
 
 type Command func(*MessageRequest, string) (string, error)
@@ -50,3 +53,11 @@ func VersionCommand(*MessageRequest, string) (string, error) {
 func SourceCommand(*MessageRequest, string) (string, error) {
 	return Source(), nil
 }
+
+// MoreCommand continues the last long reply to the channel or nick.
+func MoreCommand(req *MessageRequest, arg string) (string, error) {
+	if req.irc.More(req.target()) == ErrNoMore {
+		return "No more lines", nil
+	}
+	return "", nil
+}
@@ -7,21 +7,50 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"net/url"
 	"os"
+	"strings"
+	"time"
 )
 
 const (
 	DefaultBotTrigger     = '/'
 	DefaultChannelTrigger = '!'
 	DefaultChannelLang    = "C"
+	DefaultReconnectMax   = 5 * time.Minute
+	DefaultPingTimeout    = 4 * time.Minute
+	MinPingTimeout        = 2 * Ping_interval
+	DefaultRejoinDelay    = 10 * time.Second
+	DefaultRejoinMax      = 3
+	DefaultJoinRetryMax   = 5
+	DefaultMaxCrashes     = 3
+)
+
+// SASL mechanisms
+const (
+	SaslPlain    = "PLAIN"
+	SaslExternal = "EXTERNAL"
 )
 
 type ChannelConfig struct {
 	Name           string
+	Key            string
 	Trigger        byte
 	IgnoreURLTitle bool
 	Lang           string
 	Repaste        bool
+	AutoRejoin     bool
+	RejoinDelay    int
+	RejoinMax      int
+}
+
+// ServerConfig is one of the servers of a network.
+type ServerConfig struct {
+	Host        string
+	Port        int
+	Ssl         bool
+	ServerName  string
+	Fingerprint string
 }
 
 type IRCConfig struct {
@@ -29,16 +58,43 @@ type IRCConfig struct {
 	Server          string
 	Port            int
 	Ssl             bool
+	Servers         []*ServerConfig
+	ReconnectMax    int
+	PingTimeout     int
+	TLSServerName   string
+	TLSCAFile       string
+	TLSFingerprint  string
+	TLSInsecure     bool
+	TLSCert         string
+	TLSKey          string
+	TLSExpiryWarn   int
+	Proxy           string
+	BindAddr        string
+	AddressFamily   string
 	BotNick         string
+	AltNicks        []string
+	NickRecovery    string
 	Username        string
 	RealName        string
 	Identify_passwd string
+	SaslMech        string
+	SaslAccount     string
+	SaslCert        string
+	SaslKey         string
 	Trigger         byte
 	RawLogging      bool
+	SendBurst       int
+	SendRate        float64
+	SendQueueMax    int
+	MaxLines        int
+	JoinRetryMax    int
+	JoinChanServ    bool
 	AutoConnect     bool
 	DebugMode       bool
 	RedirectTo      string
 	Channels        []*ChannelConfig
+	Settings        Settings
+	ChannelSettings map[string]Settings
 }
 
 type BotConfig struct {
@@ -51,13 +107,22 @@ type BotConfig struct {
 	Trigger       byte
 	CompileServer string
 	YoutubeAPIKey string
+	Owners        []string
+	ControlSocket string
+	MaxCrashes    int
+	EventQueue    int
+	Modules       map[string]bool
 	IRC           []*IRCConfig
 }
 
 func (config *IRCConfig) String() string {
-	return fmt.Sprintf("%s %s:%d %s",
+	return fmt.Sprintf("%s %s %s",
 		config.Name,
-		config.Server, config.Port, config.BotNick)
+		config.GetServers()[0], config.BotNick)
+}
+
+func (config *ServerConfig) String() string {
+	return fmt.Sprintf("%s:%d", config.Host, config.Port)
 }
 
 func (config *BotConfig) String() string {
@@ -144,6 +209,50 @@ func SaveToFile(config *BotConfig, path string) error {
 	return nil
 }
 
+// redacted replaces the secrets in the config shown to the users
+const redacted = "xxxxx"
+
+// Redacted returns a copy of the config with the passwords, keys and
+// proxy credentials replaced.
+func (config *BotConfig) Redacted() (*BotConfig, error) {
+	var buf bytes.Buffer
+	var nc *BotConfig
+
+	if err := json.NewEncoder(&buf).Encode(config); err != nil {
+		return nil, err
+	}
+	nc = new(BotConfig)
+	if err := json.NewDecoder(&buf).Decode(nc); err != nil {
+		return nil, err
+	}
+	nc.path = config.path
+	nc.Proxy = redactURL(nc.Proxy)
+	if nc.YoutubeAPIKey != "" {
+		nc.YoutubeAPIKey = redacted
+	}
+	for _, irc := range nc.IRC {
+		irc.Proxy = redactURL(irc.Proxy)
+		if irc.Identify_passwd != "" {
+			irc.Identify_passwd = redacted
+		}
+		for _, ch := range irc.Channels {
+			if ch.Key != "" {
+				ch.Key = redacted
+			}
+		}
+	}
+	return nc, nil
+}
+
+// redactURL hides the password of the URL.
+func redactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.User == nil {
+		return s
+	}
+	return u.Redacted()
+}
+
 func (config *BotConfig) GetTrigger() byte {
 	c := config.Trigger
 	if c == 0 {
@@ -152,15 +261,107 @@ func (config *BotConfig) GetTrigger() byte {
 	return c
 }
 
+// GetEventQueue returns the max events queued in each lane.
+func (config *BotConfig) GetEventQueue() int {
+	if config.EventQueue > 0 {
+		return config.EventQueue
+	}
+	return DefaultEventQueue
+}
+
+// ModuleEnabled checks if the addon module is to be started,
+// modules not in Modules are enabled.
+func (config *BotConfig) ModuleEnabled(name string) bool {
+	enabled, ok := config.Modules[name]
+	return enabled || !ok
+}
+
+func (config *BotConfig) SetModuleEnabled(name string, enabled bool) {
+	if config.Modules == nil {
+		config.Modules = make(map[string]bool)
+	}
+	config.Modules[name] = enabled
+}
+
+// GetMaxCrashes returns the number of panics after which a module is disabled.
+func (config *BotConfig) GetMaxCrashes() int {
+	if config.MaxCrashes > 0 {
+		return config.MaxCrashes
+	}
+	return DefaultMaxCrashes
+}
+
 func (config *BotConfig) GetIRC(server string) *IRCConfig {
 	for i := range config.IRC {
-		if config.IRC[i].Server == server {
-			return config.IRC[i]
+		for _, s := range config.IRC[i].GetServers() {
+			if s.Host == server {
+				return config.IRC[i]
+			}
 		}
 	}
 	return nil
 }
 
+// GetServers returns the servers to connect to in order,
+// Server, Port and Ssl are used if Servers is not configured.
+func (config *IRCConfig) GetServers() []*ServerConfig {
+	if len(config.Servers) > 0 {
+		return config.Servers
+	}
+	return []*ServerConfig{
+		&ServerConfig{
+			Host: config.Server,
+			Port: config.Port,
+			Ssl:  config.Ssl,
+		},
+	}
+}
+
+// GetNetwork returns the network to dial, AddressFamily is
+// one of ipv4 and ipv6, or empty for both.
+func (config *IRCConfig) GetNetwork() string {
+	switch strings.ToLower(config.AddressFamily) {
+	case "ipv4", "4":
+		return "tcp4"
+	case "ipv6", "6":
+		return "tcp6"
+	}
+	return "tcp"
+}
+
+// GetPingTimeout returns the time without data from the server
+// after which the connection is considered dead. It is checked before
+// each PING, so a quiet server is idle for a Ping_interval plus the time
+// of its PONG, shorter timeouts are raised to MinPingTimeout.
+func (config *IRCConfig) GetPingTimeout() time.Duration {
+	var timeout time.Duration
+
+	if config.PingTimeout <= 0 {
+		return DefaultPingTimeout
+	}
+	timeout = time.Duration(config.PingTimeout) * time.Second
+	if timeout < MinPingTimeout {
+		return MinPingTimeout
+	}
+	return timeout
+}
+
+// GetReconnectMax returns the max wait between connection attempts.
+func (config *IRCConfig) GetReconnectMax() time.Duration {
+	if config.ReconnectMax > 0 {
+		return time.Duration(config.ReconnectMax) * time.Second
+	}
+	return DefaultReconnectMax
+}
+
+// GetJoinRetryMax returns the max attempts to join a channel after failures.
+func (config *IRCConfig) GetJoinRetryMax() int {
+	if config.JoinRetryMax > 0 {
+		return config.JoinRetryMax
+	}
+	return DefaultJoinRetryMax
+}
+
 func (config *IRCConfig) GetTrigger(channel string) string {
 	var c byte
 
@@ -200,6 +401,39 @@ func (config *IRCConfig) ChannelRepaste(channel string) bool {
 	return false
 }
 
+// GetChannelConfig returns the config of channel, nil if not configured.
+func (config *IRCConfig) GetChannelConfig(channel string) *ChannelConfig {
+	for _, ch := range config.Channels {
+		if ch.Name == channel {
+			return ch
+		}
+	}
+	return nil
+}
+
+func (config *ChannelConfig) GetRejoinDelay() time.Duration {
+	if config.RejoinDelay > 0 {
+		return time.Duration(config.RejoinDelay) * time.Second
+	}
+	return DefaultRejoinDelay
+}
+
+func (config *ChannelConfig) GetRejoinMax() int {
+	if config.RejoinMax > 0 {
+		return config.RejoinMax
+	}
+	return DefaultRejoinMax
+}
+
+func (config *IRCConfig) ChannelKey(channel string) string {
+	for _, ch := range config.Channels {
+		if ch.Name == channel {
+			return ch.Key
+		}
+	}
+	return ""
+}
+
 func (config *IRCConfig) ChannelLang(channel string) string {
 	var lang string
 	for _, ch := range config.Channels {
@@ -212,3 +446,40 @@ func (config *IRCConfig) ChannelLang(channel string) string {
 	}
 	return lang
 }
+
+// GetSaslMech returns the SASL mechanism to authenticate with,
+// if not configured it is derived from the credentials present.
+// A TLSCert alone is for CertFP, EXTERNAL needs a SaslCert.
+func (config *IRCConfig) GetSaslMech() string {
+	if config.SaslMech != "" {
+		return strings.ToUpper(config.SaslMech)
+	}
+	if config.SaslCert != "" {
+		return SaslExternal
+	}
+	if config.Identify_passwd != "" {
+		return SaslPlain
+	}
+	return ""
+}
+
+// GetTLSCert returns the client certificate and key files,
+// SaslCert is used if TLSCert is not configured.
+func (config *IRCConfig) GetTLSCert() (certFile, keyFile string) {
+	certFile, keyFile = config.TLSCert, config.TLSKey
+	if certFile == "" {
+		certFile, keyFile = config.SaslCert, config.SaslKey
+	}
+	if keyFile == "" {
+		// key in the same PEM file
+		keyFile = certFile
+	}
+	return
+}
+
+func (config *IRCConfig) GetSaslAccount() string {
+	if config.SaslAccount != "" {
+		return config.SaslAccount
+	}
+	return config.BotNick
+}
@@ -154,7 +154,7 @@ func (irc *IRC) onCtcp_Source(target string) {
 
 func (irc *IRC) onCtcp_Userinfo(target string) {
 	reply := fmt.Sprintf("%s (%s)",
-		irc.config.BotNick,
+		irc.CurrentNick(),
 		irc.config.RealName)
 	irc.CtcpReply(USERINFO, target, reply)
 }
@@ -3,15 +3,41 @@
 package bot
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"strings"
 	"unicode"
 )
 
+var (
+	ErrUnknownCommand   = errors.New("Unknown command")
+	ErrInsufficientArgs = errors.New("Insufficient arguments")
+	ErrPrivateOnly      = errors.New("Only available in private message")
+	ErrControlSelf      = errors.New("Control can not stop itself")
+)
+
+// EngineRequest is a bot command with the writer for its output.
+type EngineRequest struct {
+	Out  io.Writer
+	Args string
+	// the network the command is from, nil for all networks
+	IRC *IRC
+}
+
+func (req *EngineRequest) Printf(format string, a ...interface{}) {
+	fmt.Fprintf(req.Out, format+"\n", a...)
+}
+
+type EngineCommand func(*EngineRequest) error
+
 type CommandEngine struct {
 	BaseModule
 	bot      *Bot
 	inputCh  chan string
-	commands map[string]func(string) error
+	commands map[string]EngineCommand
 }
 
 func NewCommandEngine(bot *Bot) *CommandEngine {
@@ -23,7 +49,7 @@ func NewCommandEngine(bot *Bot) *CommandEngine {
 	e.inputCh = make(chan string)
 	e.Logger = bot.Logger
 
-	e.commands = make(map[string]func(string) error)
+	e.commands = make(map[string]EngineCommand)
 	e.commands["SAVE"] = e.onSave
 	e.commands["SHOW"] = e.onShow
 	e.commands["STATUS"] = e.onStatus
@@ -32,6 +58,14 @@ func NewCommandEngine(bot *Bot) *CommandEngine {
 	e.commands["DISCONNECT"] = e.onDisconnect
 	e.commands["RECONNECT"] = e.onReconnect
 	e.commands["MSG"] = e.onMsg
+	e.commands["JOIN"] = e.onJoin
+	e.commands["PART"] = e.onPart
+	e.commands["NICK"] = e.onNick
+	e.commands["RAW"] = e.onRaw
+	e.commands["MODULES"] = e.onModules
+	e.commands["ENABLE"] = e.onEnable
+	e.commands["DISABLE"] = e.onDisable
+	e.commands["RESTART"] = e.onRestart
 
 	return e
 }
@@ -91,41 +125,71 @@ func (e *CommandEngine) Submit(input string) {
 
 func (e *CommandEngine) handleCommand(input string) {
 	var err error
-	var data string
 
-	if input[0] != e.bot.config.GetTrigger() {
+	if input == "" || input[0] != e.bot.config.GetTrigger() {
 		return
 	}
 
-	if unicode.IsSpace(rune(input[1])) {
+	if len(input) < 2 || unicode.IsSpace(rune(input[1])) {
 		e.Logger.Print("Invalid command, space after trigger")
 		return
 	}
 
-	cmd := input[1:]
-	arr := strings.SplitN(cmd, " ", 2)
-	cmd = arr[0]
-	cmd = strings.ToUpper(cmd)
+	err = e.Execute(&logWriter{e.Logger}, nil, input[1:])
+	if err != nil {
+		e.Logger.Printf("Failed to handle command %s: %s", input[1:], err)
+	}
+}
+
+// Execute runs the command line without trigger, output is written to out.
+func (e *CommandEngine) Execute(out io.Writer, irc *IRC, input string) error {
+	var cmd, data string
+
+	arr := strings.SplitN(strings.TrimSpace(input), " ", 2)
+	cmd = strings.ToUpper(arr[0])
 	if len(arr) == 2 {
-		data = arr[1]
-	} else {
-		data = ""
+		data = strings.TrimSpace(arr[1])
 	}
 	f, ok := e.commands[cmd]
 	if !ok {
-		e.Logger.Print("Unknown command: ", cmd)
-		return
+		return fmt.Errorf("%s: %s", ErrUnknownCommand, cmd)
 	}
 
 	e.Logger.Printf("Bot command %s", cmd)
-	err = f(data)
-	if err != nil {
-		e.Logger.Printf("Failed to handle command %s: %s", cmd, err)
+	return f(&EngineRequest{out, data, irc})
+}
+
+// Commands returns the names of the commands.
+func (e *CommandEngine) Commands() []string {
+	var names []string
+
+	for name := range e.commands {
+		names = append(names, name)
+	}
+	return names
+}
+
+// command wraps the engine command for the interpreter,
+// the output is returned as the reply.
+func (e *CommandEngine) command(name string) Command {
+	return func(req *MessageRequest, args string) (string, error) {
+		var buf bytes.Buffer
+
+		if req.ischan {
+			return ErrPrivateOnly.Error(), nil
+		}
+		err := e.Execute(&buf, req.irc, name+" "+args)
+		if err != nil {
+			buf.WriteString(err.Error())
+		} else if buf.Len() == 0 {
+			buf.WriteString("Done")
+		}
+		return strings.TrimSpace(buf.String()), nil
 	}
 }
 
 // maitains the comand map
-func (e *CommandEngine) AddCommand(cmd string, f func(string) error) {
+func (e *CommandEngine) AddCommand(cmd string, f EngineCommand) {
 	e.commands[cmd] = f
 }
 
@@ -133,67 +197,208 @@ func (e *CommandEngine) DelCommand(cmd string) {
 	delete(e.commands, cmd)
 }
 
+// foreachIRC calls f on the network of the request, or all of them.
+func (e *CommandEngine) foreachIRC(req *EngineRequest, f func(*IRC)) {
+	if req.IRC != nil {
+		f(req.IRC)
+		return
+	}
+	e.bot.foreachIRC(f)
+}
+
 // bot command handlers
-func (e *CommandEngine) onSave(string) error {
-	return e.bot.config.Save(e.bot.config.path)
+func (e *CommandEngine) onSave(req *EngineRequest) error {
+	if err := e.bot.config.Save(e.bot.config.path); err != nil {
+		return err
+	}
+	req.Printf("Config saved to %s", e.bot.config.path)
+	return nil
 }
 
-func (e *CommandEngine) onShow(string) error {
-	e.Logger.Println("======== Config: ========")
-	e.Logger.Printf("%#v\n", e.bot.config)
-	e.Logger.Print("=========================")
+// onShow prints the config without the passwords and keys,
+// the output may go to IRC and the logs.
+func (e *CommandEngine) onShow(req *EngineRequest) error {
+	config, err := e.bot.config.Redacted()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	req.Printf("======== Config: ========")
+	req.Printf("%s", data)
+	req.Printf("=========================")
 	return nil
 }
 
-func (e *CommandEngine) onStatus(string) error {
+func (e *CommandEngine) onStatus(req *EngineRequest) error {
 	var mod Module
-	e.Logger.Print("======== Status: ========")
+	req.Printf("======== Status: ========")
 	for _, mod = range e.bot.modules {
-		e.Logger.Printf("Module %s %s", mod, mod.Status())
+		req.Printf("Module %s %s", mod, mod.Status())
+	}
+	req.Printf("Events: %s", e.bot.lanes.Stats())
+	if crashes := e.bot.Crashes(); crashes != "" {
+		req.Printf("Crashes: %s", crashes)
 	}
-	e.Logger.Print("=========================")
+	req.Printf("=========================")
 	return nil
 }
 
-func (e *CommandEngine) onExit(string) error {
+func (e *CommandEngine) onExit(req *EngineRequest) error {
+	req.Printf("Exiting")
 	go e.bot.Stop()
 	return nil
 }
 
-func (e *CommandEngine) onConnect(string) error {
+func (e *CommandEngine) onConnect(req *EngineRequest) error {
 	var err error
-	e.bot.foreachIRC(func(irc *IRC) {
+	e.foreachIRC(req, func(irc *IRC) {
 		err = irc.connect()
 	})
 	return err
 }
 
-func (e *CommandEngine) onDisconnect(string) error {
-	e.bot.foreachIRC(func(irc *IRC) {
+func (e *CommandEngine) onDisconnect(req *EngineRequest) error {
+	e.foreachIRC(req, func(irc *IRC) {
 		irc.disconnect()
 	})
 	return nil
 }
 
-func (e *CommandEngine) onReconnect(string) error {
-	e.onDisconnect("")
-	return e.onConnect("")
+func (e *CommandEngine) onReconnect(req *EngineRequest) error {
+	e.onDisconnect(req)
+	return e.onConnect(req)
 }
 
-func (e *CommandEngine) onMsg(args string) error {
+// MSG <nick|channel> <message>
+func (e *CommandEngine) onMsg(req *EngineRequest) error {
 	var (
 		nick string
 		msg  string
 		err  error
 	)
-	arr := strings.SplitN(args, " ", 2)
+	arr := strings.SplitN(req.Args, " ", 2)
 	if len(arr) < 2 {
-		e.Logger.Println("Insufficient args to MSG")
-		return nil
+		return ErrInsufficientArgs
 	}
 	nick, msg = arr[0], arr[1]
-	e.bot.foreachIRC(func(irc *IRC) {
+	e.foreachIRC(req, func(irc *IRC) {
 		err = irc.Privmsg(nick, msg)
 	})
 	return err
 }
+
+// JOIN <channel> [key]
+func (e *CommandEngine) onJoin(req *EngineRequest) error {
+	var channel, key string
+	var err error
+
+	arr := strings.Fields(req.Args)
+	if len(arr) < 1 {
+		return ErrInsufficientArgs
+	}
+	channel = arr[0]
+	if len(arr) > 1 {
+		key = arr[1]
+	}
+	e.foreachIRC(req, func(irc *IRC) {
+		err = irc.joinChannel(channel, key)
+	})
+	return err
+}
+
+// PART <channel> [message]
+func (e *CommandEngine) onPart(req *EngineRequest) error {
+	var channel, msg string
+	var err error
+
+	arr := strings.SplitN(req.Args, " ", 2)
+	if arr[0] == "" {
+		return ErrInsufficientArgs
+	}
+	channel = arr[0]
+	if len(arr) == 2 {
+		msg = arr[1]
+	}
+	e.foreachIRC(req, func(irc *IRC) {
+		err = irc.Part(channel, msg)
+	})
+	return err
+}
+
+// NICK <nick>
+func (e *CommandEngine) onNick(req *EngineRequest) error {
+	var err error
+
+	if req.Args == "" {
+		return ErrInsufficientArgs
+	}
+	e.foreachIRC(req, func(irc *IRC) {
+		err = irc.Nick(req.Args)
+	})
+	return err
+}
+
+// RAW <line>
+func (e *CommandEngine) onRaw(req *EngineRequest) error {
+	var err error
+
+	if req.Args == "" {
+		return ErrInsufficientArgs
+	}
+	e.foreachIRC(req, func(irc *IRC) {
+		err = irc.sendMsg(req.Args)
+	})
+	return err
+}
+
+func (e *CommandEngine) onModules(req *EngineRequest) error {
+	for _, mod := range e.bot.addons {
+		state := "disabled"
+		if e.bot.isRunning(mod) {
+			state = "enabled"
+		}
+		req.Printf("%s %s, %s", mod, state, mod.Status())
+	}
+	return nil
+}
+
+// ENABLE <module>
+func (e *CommandEngine) onEnable(req *EngineRequest) error {
+	if req.Args == "" {
+		return ErrInsufficientArgs
+	}
+	return e.bot.EnableModule(req.Args)
+}
+
+// stopsSelf checks if the module would wait for the request to finish,
+// the control connections are waited for by its Stop.
+func (e *CommandEngine) stopsSelf(req *EngineRequest) bool {
+	_, fromControl := req.Out.(*controlWriter)
+	_, isControl := e.bot.GetModule(req.Args).(*Control)
+	return fromControl && isControl
+}
+
+// DISABLE <module>
+func (e *CommandEngine) onDisable(req *EngineRequest) error {
+	if req.Args == "" {
+		return ErrInsufficientArgs
+	}
+	if e.stopsSelf(req) {
+		return ErrControlSelf
+	}
+	return e.bot.DisableModule(req.Args)
+}
+
+// RESTART <module>
+func (e *CommandEngine) onRestart(req *EngineRequest) error {
+	if req.Args == "" {
+		return ErrInsufficientArgs
+	}
+	if e.stopsSelf(req) {
+		return ErrControlSelf
+	}
+	return e.bot.RestartModule(req.Args)
+}
@@ -4,20 +4,22 @@ package bot
 
 import (
 	"fmt"
+	"reflect"
+	"sort"
+	"sync"
 )
 
 // Priority defines the order of the invoke of the handlers.
-// Handlers with High priority is invoke first.
+// Handlers with higher priority are invoked first.
 type Priority int
 
 const (
-	High Priority = iota
-	Low
+	Low    Priority = -100
+	Normal Priority = 0
+	High   Priority = 100
 )
 
 type EventType int
-type EventHandler func(interface{})
-type EventHandlers *[2][]EventHandler
 
 const (
 	Input EventType = iota
@@ -25,6 +27,7 @@ const (
 	UserPart
 	UserQuit
 	UserNick
+	UserKick
 	Pong
 	PrivateMessage
 	ChannelMessage
@@ -40,6 +43,7 @@ func (evt EventType) String() string {
 		"UserPart",
 		"UserQuit",
 		"UserNick",
+		"UserKick",
 		"Pong",
 		"PrivateMessage",
 		"ChannelMessage",
@@ -82,6 +86,7 @@ type UserJoinData struct {
 	EventBase
 	irc     *IRC
 	channel string
+	account string
 }
 
 type UserPartData struct {
@@ -103,6 +108,15 @@ type UserNickData struct {
 	newNick string
 }
 
+// UserKickData carries the kicker in EventBase.
+type UserKickData struct {
+	EventBase
+	irc     *IRC
+	channel string
+	victim  string
+	reason  string
+}
+
 // PrivateMessage
 type PrivateMessageData struct {
 	EventBase
@@ -122,3 +136,187 @@ type PongData struct {
 	from   string
 	origin string
 }
+
+// the data of the events, handlers of other event types take interface{}
+var eventDataTypes = map[EventType]reflect.Type{
+	Input:             reflect.TypeOf(""),
+	UserJoin:          reflect.TypeOf((*UserJoinData)(nil)),
+	UserPart:          reflect.TypeOf((*UserPartData)(nil)),
+	UserQuit:          reflect.TypeOf((*UserQuitData)(nil)),
+	UserNick:          reflect.TypeOf((*UserNickData)(nil)),
+	UserKick:          reflect.TypeOf((*UserKickData)(nil)),
+	Pong:              reflect.TypeOf((*PongData)(nil)),
+	PrivateMessage:    reflect.TypeOf((*PrivateMessageData)(nil)),
+	ChannelMessage:    reflect.TypeOf((*ChannelMessageData)(nil)),
+	Disconnect:        reflect.TypeOf((*IRC)(nil)),
+	MessageParseEvent: reflect.TypeOf((*MessageRequest)(nil)),
+}
+
+var contextType = reflect.TypeOf((*EventContext)(nil))
+
+func OnInput(f func(*EventContext, string), prio Priority) Handler {
+	return Handler{Input, f, prio}
+}
+
+func OnUserJoin(f func(*EventContext, *UserJoinData), prio Priority) Handler {
+	return Handler{UserJoin, f, prio}
+}
+
+func OnUserPart(f func(*EventContext, *UserPartData), prio Priority) Handler {
+	return Handler{UserPart, f, prio}
+}
+
+func OnUserQuit(f func(*EventContext, *UserQuitData), prio Priority) Handler {
+	return Handler{UserQuit, f, prio}
+}
+
+func OnUserNick(f func(*EventContext, *UserNickData), prio Priority) Handler {
+	return Handler{UserNick, f, prio}
+}
+
+func OnUserKick(f func(*EventContext, *UserKickData), prio Priority) Handler {
+	return Handler{UserKick, f, prio}
+}
+
+func OnPong(f func(*EventContext, *PongData), prio Priority) Handler {
+	return Handler{Pong, f, prio}
+}
+
+func OnPrivateMessage(f func(*EventContext, *PrivateMessageData), prio Priority) Handler {
+	return Handler{PrivateMessage, f, prio}
+}
+
+func OnChannelMessage(f func(*EventContext, *ChannelMessageData), prio Priority) Handler {
+	return Handler{ChannelMessage, f, prio}
+}
+
+func OnDisconnect(f func(*EventContext, *IRC), prio Priority) Handler {
+	return Handler{Disconnect, f, prio}
+}
+
+func OnMessageParse(f func(*EventContext, *MessageRequest), prio Priority) Handler {
+	return Handler{MessageParseEvent, f, prio}
+}
+
+// OnEvent handles any event type with the data as interface{}.
+func OnEvent(evt EventType, f func(*EventContext, interface{}), prio Priority) Handler {
+	return Handler{evt, f, prio}
+}
+
+// EventContext is passed to the handlers along with the event data.
+type EventContext struct {
+	Event   *Event
+	stopped bool
+}
+
+// Stop keeps the event from the rest of the handlers.
+func (ctx *EventContext) Stop() {
+	ctx.stopped = true
+}
+
+type subscriber struct {
+	id   uint64
+	prio Priority
+	fn   reflect.Value
+	typ  reflect.Type
+	name string
+	// the addon module registering the handler, nil for the core
+	owner Module
+}
+
+// Subscription is the handle of a registered handler.
+type Subscription struct {
+	bus *eventBus
+	evt EventType
+	id  uint64
+}
+
+// Unsubscribe removes the handler, it is not called for new events.
+func (s *Subscription) Unsubscribe() {
+	if s != nil {
+		s.bus.unsubscribe(s.evt, s.id)
+	}
+}
+
+// eventBus keeps the handlers of each event type sorted by priority,
+// the lists are replaced on change so they can be read without the lock.
+type eventBus struct {
+	sync.RWMutex
+	next uint64
+	subs map[EventType][]*subscriber
+}
+
+func newEventBus() *eventBus {
+	b := new(eventBus)
+	b.subs = make(map[EventType][]*subscriber)
+	return b
+}
+
+// checkHandler panics if handler is not a func(*EventContext, T)
+// with T the data type of the event.
+func checkHandler(evt EventType, handler interface{}) reflect.Type {
+	var data reflect.Type
+
+	t := reflect.TypeOf(handler)
+	if t == nil || t.Kind() != reflect.Func ||
+		t.NumIn() != 2 || t.NumOut() != 0 || t.In(0) != contextType {
+		panic(fmt.Sprintf("%s handler %T is not a func(*EventContext, data)", evt, handler))
+	}
+	data = t.In(1)
+	expected, ok := eventDataTypes[evt]
+	if ok && data != expected &&
+		!(data.Kind() == reflect.Interface && expected.Implements(data)) {
+		panic(fmt.Sprintf("%s handler %T takes %s, not %s", evt, handler, data, expected))
+	}
+	return data
+}
+
+func (b *eventBus) subscribe(evt EventType, handler interface{}, prio Priority) *Subscription {
+	return b.subscribeOwned(evt, handler, prio, nil)
+}
+
+func (b *eventBus) subscribeOwned(evt EventType, handler interface{}, prio Priority, owner Module) *Subscription {
+	var s *subscriber
+
+	s = &subscriber{
+		prio:  prio,
+		fn:    reflect.ValueOf(handler),
+		typ:   checkHandler(evt, handler),
+		name:  funcName(handler),
+		owner: owner,
+	}
+
+	b.Lock()
+	defer b.Unlock()
+	b.next++
+	s.id = b.next
+	old := b.subs[evt]
+	subs := make([]*subscriber, 0, len(old)+1)
+	subs = append(subs, old...)
+	subs = append(subs, s)
+	// handlers of the same priority keep the order of registration
+	sort.SliceStable(subs, func(i, j int) bool {
+		return subs[i].prio > subs[j].prio
+	})
+	b.subs[evt] = subs
+	return &Subscription{b, evt, s.id}
+}
+
+func (b *eventBus) unsubscribe(evt EventType, id uint64) {
+	b.Lock()
+	defer b.Unlock()
+	old := b.subs[evt]
+	subs := make([]*subscriber, 0, len(old))
+	for _, s := range old {
+		if s.id != id {
+			subs = append(subs, s)
+		}
+	}
+	b.subs[evt] = subs
+}
+
+func (b *eventBus) subscribers(evt EventType) []*subscriber {
+	b.RLock()
+	defer b.RUnlock()
+	return b.subs[evt]
+}
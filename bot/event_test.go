@@ -0,0 +1,55 @@
+package bot
+
+import (
+	"testing"
+)
+
+func TestEventBus(t *testing.T) {
+	var calls []string
+
+	b := newEventBus()
+	bot := &Bot{bus: b, crashes: newCrashes(), config: &BotConfig{}}
+	bot.Logger = NewTestLogger("event")
+
+	b.subscribe(Input, func(ctx *EventContext, s string) {
+		calls = append(calls, "low:"+s)
+	}, Low)
+	stop := b.subscribe(Input, func(ctx *EventContext, s string) {
+		calls = append(calls, "stop")
+		ctx.Stop()
+	}, Low+1)
+	b.subscribe(Input, func(ctx *EventContext, s string) {
+		calls = append(calls, "high")
+	}, High)
+	b.subscribe(Input, func(ctx *EventContext, data interface{}) {
+		calls = append(calls, "any")
+	}, Normal)
+
+	bot.handleEvent(NewEvent(Input, "x"))
+	if len(calls) != 3 || calls[0] != "high" || calls[1] != "any" || calls[2] != "stop" {
+		t.Error(calls)
+	}
+
+	calls = nil
+	stop.Unsubscribe()
+	bot.handleEvent(NewEvent(Input, "y"))
+	if len(calls) != 3 || calls[2] != "low:y" {
+		t.Error(calls)
+	}
+
+	// data of the wrong type is not passed to the handlers
+	calls = nil
+	bot.handleEvent(NewEvent(Input, 1))
+	if len(calls) != 1 || calls[0] != "any" {
+		t.Error(calls)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("mismatched handler accepted")
+			}
+		}()
+		b.subscribe(Pong, func(ctx *EventContext, req *MessageRequest) {}, Normal)
+	}()
+}
@@ -7,6 +7,7 @@ import (
 	"net/url"
 	"regexp"
 	"strings"
+	"sync"
 
 	"github.com/mvdan/xurls"
 )
@@ -19,12 +20,17 @@ type Interpreter struct {
 	reqCh   chan *MessageRequest
 	reqExCh chan bool
 
+	// commands are added and removed by the modules at runtime
 	commands map[string]Command
+	owners   map[string]Module
+	cmdLock  sync.RWMutex
 
-	nickRe *regexp.Regexp
-	msgRe1 *regexp.Regexp
-	msgRe2 *regexp.Regexp
-	msgRe3 *regexp.Regexp
+	// regexps built from the current nick
+	nickLock sync.Mutex
+	nickRe   *regexp.Regexp
+	msgRe1   *regexp.Regexp
+	msgRe2   *regexp.Regexp
+	msgRe3   *regexp.Regexp
 
 	total uint
 }
@@ -37,25 +43,54 @@ func NewInterpreter(irc *IRC) *Interpreter {
 	i.reqExCh = make(chan bool)
 
 	i.commands = make(map[string]Command)
+	i.owners = make(map[string]Module)
 	i.RegisterCommand("VERSION", VersionCommand)
 	i.RegisterCommand("SOURCE", SourceCommand)
-
-	i.nickRe = regexp.MustCompile(
-		fmt.Sprintf("\\b%s\\b", irc.config.BotNick))
+	i.RegisterCommand("MORE", MoreCommand)
+	i.RegisterCommand("ADDUSER", AddUserCommand)
+	i.RegisterCommand("DELUSER", DelUserCommand)
+	i.RegisterCommand("USERS", UsersCommand)
+	i.RegisterCommand("LEVEL", LevelCommand)
+	i.RegisterCommand("SET", SetSettingCommand)
+	i.RegisterCommand("GET", GetSettingCommand)
+	irc.bot.perms.SetDefault("ADDUSER", Admin)
+	irc.bot.perms.SetDefault("DELUSER", Admin)
+	irc.bot.perms.SetDefault("USERS", Trusted)
+	irc.bot.perms.SetDefault("LEVEL", Admin)
+	irc.bot.perms.SetDefault("SET", ChanOp)
+
+	// the bot commands in private message
+	for _, name := range irc.bot.engine.Commands() {
+		i.RegisterCommand(name, irc.bot.engine.command(name))
+		irc.bot.perms.SetDefault(name, Admin)
+	}
+	irc.bot.perms.SetDefault("EXIT", Owner)
+	irc.bot.perms.SetDefault("SHOW", Owner)
+	irc.bot.perms.SetDefault("RAW", Owner)
 
 	// ?version
 	trigger := i.irc.config.GetTrigger("")
 	trigger = regexp.QuoteMeta(trigger)
 	msgPtn1 := fmt.Sprintf("^%s(.*)$", trigger)
+	i.msgRe1 = regexp.MustCompile(msgPtn1)
+
+	i.setNick(irc.config.BotNick)
+	return i
+}
+
+// setNick rebuilds the regexps matching messages addressed to nick.
+func (i *Interpreter) setNick(nick string) {
+	nick = regexp.QuoteMeta(nick)
 	// me: version
-	msgPtn2 := fmt.Sprintf("^%s[:,;.]?(?:\\s+)?(.*)$", i.irc.config.BotNick)
+	msgPtn2 := fmt.Sprintf("^%s[:,;.]?(?:\\s+)?(.*)$", nick)
 	// version, me
-	msgPtn3 := fmt.Sprintf("^(.*)(?:[,.:;]) %s$", i.irc.config.BotNick)
+	msgPtn3 := fmt.Sprintf("^(.*)(?:[,.:;]) %s$", nick)
 
-	i.msgRe1 = regexp.MustCompile(msgPtn1)
+	i.nickLock.Lock()
+	i.nickRe = regexp.MustCompile(fmt.Sprintf("\\b%s\\b", nick))
 	i.msgRe2 = regexp.MustCompile(msgPtn2)
 	i.msgRe3 = regexp.MustCompile(msgPtn3)
-	return i
+	i.nickLock.Unlock()
 }
 
 func (i *Interpreter) String() string {
@@ -92,18 +127,44 @@ func (i *Interpreter) Status() string {
 
 // commands management
 func (i *Interpreter) RegisterCommand(name string, cmd Command) {
+	i.registerCommand(name, cmd, nil)
+}
+
+// registerCommand adds the command of the addon module mod,
+// nil for the core commands.
+func (i *Interpreter) registerCommand(name string, cmd Command, mod Module) {
 	name = strings.ToUpper(name)
+	i.cmdLock.Lock()
 	i.commands[name] = cmd
+	if mod != nil {
+		i.owners[name] = mod
+	} else {
+		delete(i.owners, name)
+	}
+	i.cmdLock.Unlock()
 }
 
 func (i *Interpreter) DelCommand(name string) {
 	name = strings.ToUpper(name)
+	i.cmdLock.Lock()
 	delete(i.commands, name)
+	delete(i.owners, name)
+	i.cmdLock.Unlock()
+}
+
+// commandOwner returns the addon module that registered the command.
+func (i *Interpreter) commandOwner(name string) Module {
+	name = strings.ToUpper(name)
+	i.cmdLock.RLock()
+	defer i.cmdLock.RUnlock()
+	return i.owners[name]
 }
 
 func (i *Interpreter) GetCommand(name string) Command {
 	name = strings.ToUpper(name)
+	i.cmdLock.RLock()
 	cmd, ok := i.commands[name]
+	i.cmdLock.RUnlock()
 	if ok {
 		return cmd
 	}
@@ -139,9 +200,9 @@ func (i *Interpreter) sendReply(res string, req *MessageRequest) {
 		return
 	}
 	if req.prefix {
-		i.irc.Privmsg(req.channel, fmt.Sprintf("%s: %s", req.nick, res))
+		i.irc.Privmsg(req.target(), fmt.Sprintf("%s: %s", req.nick, res))
 	} else {
-		i.irc.Privmsg(req.channel, res)
+		i.irc.Privmsg(req.target(), res)
 	}
 }
 
@@ -150,7 +211,10 @@ func (i *Interpreter) handleRequest(req *MessageRequest) {
 	i.Logger.Printf("%s", req)
 
 	i.total++
-	if i.nickRe.FindStringIndex(req.text) != nil {
+	i.nickLock.Lock()
+	nickRe, msgRe2, msgRe3 := i.nickRe, i.msgRe2, i.msgRe3
+	i.nickLock.Unlock()
+	if nickRe.FindStringIndex(req.text) != nil {
 		req.direct = true
 	}
 	req.prefix = req.ischan && req.direct
@@ -173,12 +237,12 @@ func (i *Interpreter) handleRequest(req *MessageRequest) {
 		command = m[1]
 		goto Found
 	}
-	m = i.msgRe2.FindStringSubmatch(text)
+	m = msgRe2.FindStringSubmatch(text)
 	if m != nil {
 		command = m[1]
 		goto Found
 	}
-	m = i.msgRe3.FindStringSubmatch(text)
+	m = msgRe3.FindStringSubmatch(text)
 	if m != nil {
 		command = m[1]
 		goto Found
@@ -200,9 +264,15 @@ Found:
 
 	cmd = i.GetCommand(keyword)
 	if cmd != nil {
+		if err := i.authorize(req, keyword); err != nil {
+			i.Logger.Printf("%s rejected for %s: %s", keyword, req.from, err)
+			i.sendReply(err.Error(), req)
+			return
+		}
 		i.Logger.Printf("calling %s with [%s]", keyword, arguments)
-		res, err := cmd(req, arguments)
+		res, err := i.invoke(keyword, cmd, req, arguments)
 		if err == nil {
+			// long results are paged by Privmsg
 			i.sendReply(res, req)
 		} else {
 			i.Logger.Printf("%s error: %s", keyword, err)
@@ -214,6 +284,29 @@ Found:
 	return
 }
 
+// invoke calls the command, a panic is recovered and returned as error.
+func (i *Interpreter) invoke(keyword string, cmd Command, req *MessageRequest, args string) (res string, err error) {
+	var name string
+	var mod Module
+
+	name = funcName(cmd)
+	if mod = i.commandOwner(keyword); mod != nil {
+		if i.irc.bot.isDisabled(mod) {
+			return "", fmt.Errorf("%s is disabled", mod)
+		}
+		if !i.irc.ModuleEnabled(req.channel, fmt.Sprint(mod)) {
+			return "", fmt.Errorf("%s is disabled in %s", mod, req.target())
+		}
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			i.irc.bot.onPanic(name, mod, r)
+			err = fmt.Errorf("%s panicked: %v", keyword, r)
+		}
+	}()
+	return cmd(req, args)
+}
+
 func (i *Interpreter) parse(req *MessageRequest) {
 	urls := xurls.Strict.FindString(req.text)
 	if len(urls) > 0 {
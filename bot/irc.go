@@ -6,9 +6,12 @@ import (
 	"crypto/tls"
 	"fmt"
 	"log"
+	"math/rand"
 	"net"
 	"regexp"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -19,29 +22,22 @@ const (
 	lf            byte   = '\n'
 	crlf          string = "\r\n"
 
-	msgPtn     = "^(:[^ \000]+ )?([[:alpha:]]+|[[:digit:]]{3}) (.*)$"
 	tgtPtn     = "^([A-Za-z\\[\\]\\\\`_\\^{|}][A-Za-z0-9\\[\\]\\\\`_\\^{|}-]{0,15})!([^ @\000]+)@([a-zA-Z0-9:/.-]*)$"
 	rpl_002Ptn = "Your host is ([a-zA-Z0-9.-]*)(\\[[0-9./]*\\])?, running version (.*)"
 )
 
 var (
-	msgRe     *regexp.Regexp
 	nickRe    *regexp.Regexp
 	rpl_002Re *regexp.Regexp
 )
 
 func init() {
-	msgRe = regexp.MustCompile(msgPtn)
 	nickRe = regexp.MustCompile(tgtPtn)
 	rpl_002Re = regexp.MustCompile(rpl_002Ptn)
 }
 
-type IRCCommand struct {
-	cmd, prefix, param string
-}
-
-// IRC command handler, args: prefix, params
-type CommandHandler func(string, string)
+// IRC command handler, args: the parsed message
+type CommandHandler func(*Message)
 
 type IRC struct {
 	BaseModule
@@ -51,18 +47,45 @@ type IRC struct {
 	rawLogger *log.Logger
 
 	stopping bool
-	conn     net.Conn
+	// closed by Stop to interrupt the wait between connection attempts
+	stopCh chan bool
+	conn   net.Conn
+
+	server      *ServerConfig
+	serverIdx   int
+	attempts    int
+	nextAttempt time.Time
 
 	host     string
 	version  string
 	mode     string
-	supports []string
+	isupport *ISupport
 	cloak    string
-
-	lag time.Duration
-
+	caps     *capSet
+	account  string
+	authErr  error
+
+	// current nick, may differ from config.BotNick if it was taken
+	nick       string
+	nickLock   sync.Mutex
+	nickTries  int
+	registered bool
+	// backoff of the recovery attempts by the timer
+	nickRetry   time.Duration
+	nickRetryAt time.Time
+
+	lag      time.Duration
+	lastRecv int64
+
+	sendq     *sendQueue
+	source    string
+	more      map[string]*pendingText
+	moreLock  sync.Mutex
+	writeLock sync.Mutex
+	// guards conn, which is closed and cleared by other goroutines
+	connLock  sync.Mutex
 	msgCh     chan string
-	cmdCh     chan *IRCCommand
+	cmdCh     chan *Message
 	msgExCh   chan bool
 	cmdExCh   chan bool
 	timer     *time.Ticker
@@ -71,6 +94,15 @@ type IRC struct {
 	handlers    map[string]CommandHandler
 	channels    map[string]*Channel
 	interpreter *Interpreter
+
+	rejoins    map[string]*rejoinState
+	rejoinLock sync.Mutex
+	joins      map[string]*channelJoin
+	joinLock   sync.Mutex
+
+	// services accounts of the nicks, keyed by folded nick
+	accounts    map[string]string
+	accountLock sync.Mutex
 }
 
 func NewIRC(bot *Bot, config *IRCConfig) *IRC {
@@ -93,13 +125,22 @@ func NewIRC(bot *Bot, config *IRCConfig) *IRC {
 	}
 	irc.State = Disconnected
 	irc.exitCh = make(chan bool)
+	irc.stopCh = make(chan bool)
+	irc.sendq = newSendQueue(config.SendBurst, config.SendRate, config.SendQueueMax)
 	irc.msgCh = make(chan string)
-	irc.cmdCh = make(chan *IRCCommand)
+	irc.cmdCh = make(chan *Message)
 	irc.msgExCh = make(chan bool)
 	irc.cmdExCh = make(chan bool)
 	irc.timer = nil
 	irc.timerExCh = make(chan bool)
 	irc.channels = make(map[string]*Channel)
+	irc.more = make(map[string]*pendingText)
+	irc.rejoins = make(map[string]*rejoinState)
+	irc.joins = make(map[string]*channelJoin)
+	irc.accounts = make(map[string]string)
+	irc.caps = newCapSet()
+	irc.isupport = NewISupport()
+	irc.nick = config.BotNick
 	irc.interpreter = NewInterpreter(irc)
 	// IRC internal handlers, plugins should use Events to register
 	irc.handlers = map[string]CommandHandler{
@@ -108,6 +149,7 @@ func NewIRC(bot *Bot, config *IRCConfig) *IRC {
 		"PRIVMSG": irc.onPrivmsg,
 		"JOIN":    irc.onJoin,
 		"PART":    irc.onPart,
+		"KICK":    irc.onKick,
 		"QUIT":    irc.onQuit,
 		"NICK":    irc.onNick,
 		"INVITE":  irc.onInvite,
@@ -115,6 +157,27 @@ func NewIRC(bot *Bot, config *IRCConfig) *IRC {
 		"MODE":    irc.onMode,
 		"ERROR":   irc.onError,
 
+		"ERR_NICKNAMEINUSE":    irc.onERR_NICKNAMEINUSE,
+		"ERR_ERRONEUSNICKNAME": irc.onERR_NICKNAMEINUSE,
+		"ERR_UNAVAILRESOURCE":  irc.onERR_NICKNAMEINUSE,
+		"RPL_MONONLINE":        irc.onRPL_MONONLINE,
+		"RPL_MONOFFLINE":       irc.onRPL_MONOFFLINE,
+
+		"CAP":     irc.onCap,
+		"ACCOUNT": irc.onAccount,
+		"AWAY":    irc.onAway,
+
+		"AUTHENTICATE":    irc.onAuthenticate,
+		"RPL_LOGGEDIN":    irc.onRPL_LOGGEDIN,
+		"RPL_LOGGEDOUT":   irc.onRPL_LOGGEDOUT,
+		"ERR_NICKLOCKED":  irc.onERR_NICKLOCKED,
+		"RPL_SASLSUCCESS": irc.onRPL_SASLSUCCESS,
+		"ERR_SASLFAIL":    irc.onERR_SASLFAIL,
+		"ERR_SASLTOOLONG": irc.onERR_SASLTOOLONG,
+		"ERR_SASLABORTED": irc.onERR_SASLABORTED,
+		"ERR_SASLALREADY": irc.onERR_SASLALREADY,
+		"RPL_SASLMECHS":   irc.onRPL_SASLMECHS,
+
 		"RPL_WELCOME":  irc.onRPL_WELCOME,
 		"RPL_YOURHOST": irc.onRPL_YOURHOST,
 		"RPL_CREATED":  irc.onRPL_CREATED,
@@ -149,8 +212,13 @@ func NewIRC(bot *Bot, config *IRCConfig) *IRC {
 		"RPL_TOPIC":         irc.onRPL_TOPIC,
 		"RPL_TOPICWHOTIME":  irc.onRPL_TOPICWHOTIME,
 
-		"RPL_NAMREPLY":   irc.onRPL_NAMREPLY,
-		"RPL_ENDOFNAMES": irc.onRPL_ENDOFNAMES,
+		"RPL_CHANNELMODEIS": irc.onRPL_CHANNELMODEIS,
+		"RPL_BANLIST":       irc.onRPL_BANLIST,
+		"RPL_ENDOFBANLIST":  irc.onRPL_ENDOFBANLIST,
+		"RPL_EXCEPTLIST":    irc.onRPL_EXCEPTLIST,
+		"RPL_INVEXLIST":     irc.onRPL_INVEXLIST,
+		"RPL_NAMREPLY":      irc.onRPL_NAMREPLY,
+		"RPL_ENDOFNAMES":    irc.onRPL_ENDOFNAMES,
 
 		"RPL_MOTD":      irc.onRPL_MOTD,
 		"RPL_MOTDSTART": irc.onRPL_MOTDSTART,
@@ -160,7 +228,12 @@ func NewIRC(bot *Bot, config *IRCConfig) *IRC {
 
 		"RPL_HOSTHIDDEN": irc.onRPL_HOSTHIDDEN,
 
-		"ERR_INVITEONLYCHAN": irc.onERR_INVITEONLYCHAN,
+		"ERR_TOOMANYCHANNELS": irc.onJoinError,
+		"ERR_CHANNELISFULL":   irc.onJoinError,
+		"ERR_INVITEONLYCHAN":  irc.onJoinError,
+		"ERR_BANNEDFROMCHAN":  irc.onJoinError,
+		"ERR_BADCHANNELKEY":   irc.onJoinError,
+		"ERR_NEEDREGGEDNICK":  irc.onJoinError,
 
 		"RPL_WHOISSECURE": irc.onRPL_WHOISSECURE,
 	}
@@ -180,11 +253,21 @@ func (irc *IRC) Init() error {
 }
 
 func (irc *IRC) Status() string {
-	if irc.conn != nil {
-		return fmt.Sprintf("Connected to: %s %s as %s@%s\n"+
-			"State: %s\nChannels(%d): %s",
-			irc.host, irc.version, irc.config.BotNick, irc.cloak,
-			irc.State, len(irc.channels), irc.channels)
+	if irc.getConn() != nil {
+		queued, dropped := irc.sendq.stats()
+		return fmt.Sprintf("Connected to: %s(%s) %s as %s@%s\n"+
+			"State: %s\nNetwork: %s\nAccount: %s\nCapabilities: %s\n"+
+			"Send queue: %d queued, %d dropped\nLast activity: %s ago\n"+
+			"Channels(%d): %s\nJoins: %s",
+			irc.server, irc.host, irc.version, irc.CurrentNick(), irc.cloak,
+			irc.State, irc.isupport.Network(), irc.account, irc.Caps(),
+			queued, dropped, irc.idle()/time.Second*time.Second,
+			len(irc.channels), irc.channels, irc.joinStatus())
+	} else if irc.attempts > 0 {
+		return fmt.Sprintf("Not connected, State: %s\n"+
+			"Reconnecting: attempt %d, next at %s to %s",
+			irc.State, irc.attempts,
+			irc.nextAttempt.Format(time.RFC3339), irc.nextServer())
 	} else {
 		return fmt.Sprintf("Not connected, State: %s",
 			irc.State)
@@ -198,9 +281,10 @@ func (irc *IRC) Start() error {
 }
 
 func (irc *IRC) Run() {
-	irc.wait.Add(2)
+	irc.wait.Add(3)
 	go irc.messageLoop()
 	go irc.commandLoop()
+	go irc.sendLoop()
 	irc.interpreter.Run()
 
 	if irc.config.AutoConnect {
@@ -213,11 +297,13 @@ func (irc *IRC) Run() {
 
 func (irc *IRC) Stop() error {
 	irc.stopping = true
-	if irc.conn != nil {
+	close(irc.stopCh)
+	if irc.getConn() != nil {
 		irc.Quit("Exiting...")
 		irc.disconnect()
 	}
 	irc.interpreter.Stop()
+	irc.sendq.close()
 	irc.msgExCh <- true
 	irc.cmdExCh <- true
 
@@ -233,61 +319,48 @@ func (irc *IRC) Stop() error {
 func (irc *IRC) connect() error {
 	var err error
 	var addr string
-	var raddr *net.TCPAddr
-	var tcpConn *net.TCPConn
+	var tcpConn net.Conn
+	var server *ServerConfig
+	var wait time.Duration
 
 	if irc.State >= Running {
 		return nil
 	}
+	irc.authErr = nil
 
 	for {
-		addr = fmt.Sprintf("%s:%d",
-			irc.config.Server,
-			irc.config.Port)
+		server = irc.nextServer()
+		addr = server.String()
 		irc.Logger.Printf("Connecting to IRC server %s", addr)
-		raddr, err = net.ResolveTCPAddr("tcp", addr)
-		if err != nil {
-			irc.Logger.Printf("Failed to resolve irc server %s", addr)
-			irc.Logger.Println(err)
-			goto fail
-		}
-
-		tcpConn, err = net.DialTCP("tcp", nil, raddr)
+		tcpConn, err = irc.dial(addr)
 		if err != nil {
 			irc.Logger.Printf("Failed to connect to irc server: %s", err)
 			goto fail
 		}
-		irc.conn = tcpConn
-		if irc.config.Ssl {
+		irc.setConn(tcpConn)
+		irc.server = server
+		if server.Ssl {
 			irc.Logger.Println("Connecting using tls")
 			var tlsConn *tls.Conn
-			var tlsConfig tls.Config
+			var tlsConfig *tls.Config
 
-			// tlsConfig.InsecureSkipVerify = true
-			tlsConfig.ServerName = "freenode.net"
-			tlsConn = tls.Client(tcpConn, &tlsConfig)
-			//  conn, err = tls.Dial("tcp", addr, &tlsConfig)
+			tlsConfig, err = irc.tlsConfig(server)
+			if err != nil {
+				irc.Logger.Printf("Invalid tls configuration: %s", err)
+				goto fail
+			}
+			tlsConn = tls.Client(tcpConn, tlsConfig)
 			err = tlsConn.Handshake()
 			if err != nil {
 				irc.Logger.Printf("Failed to run tls handshake: %s", err)
 				goto fail
 			}
-			// fmt.Println(tlsConn)
-			state := tlsConn.ConnectionState()
-			irc.Logger.Printf("Version %X Cipher %X",
-				state.Version,
-				state.CipherSuite)
-			irc.Logger.Printf("receiving %d certificates",
-				len(state.PeerCertificates))
-			for i, cert := range state.PeerCertificates {
-				irc.Logger.Printf("certificate %d: %s", i, dumpCert(cert))
-			}
-			// fmt.Println("ct", tlsConn.ConnectionState().PeerCertificates)
-			irc.conn = tlsConn
+			irc.checkCerts(tlsConn.ConnectionState())
+			irc.setConn(tlsConn)
 		}
 
 		irc.Logger.Printf("Connected %s <--> %s",
-			irc.conn.LocalAddr(), irc.conn.RemoteAddr())
+			irc.getConn().LocalAddr(), irc.getConn().RemoteAddr())
 		irc.State = Connected
 		irc.Logger.Println("IRC connected")
 
@@ -298,17 +371,30 @@ func (irc *IRC) connect() error {
 		}
 		irc.State = Identified
 		irc.Logger.Println("IRC regiested")
-
-		err = irc.joinChannels()
-		if err != nil {
-			irc.Logger.Println("Failed to join pre-configured channels", err)
-			goto fail
-		}
+		irc.attempts = 0
+		irc.nextAttempt = time.Time{}
 		break
 	fail:
-		time.Sleep(connect_wait)
+		if conn := irc.takeConn(); conn != nil {
+			conn.Close()
+		}
+		// try the next server after a failure
+		irc.serverIdx++
+		irc.attempts++
+		wait = backoff(irc.attempts, connect_wait, irc.config.GetReconnectMax())
+		irc.nextAttempt = time.Now().Add(wait)
+		irc.Logger.Printf("Connection attempt %d failed, retrying in %s",
+			irc.attempts, wait)
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-irc.stopCh:
+			timer.Stop()
+			return err
+		}
 	}
 
+	irc.setLastRecv(time.Now())
 	irc.timer = time.NewTicker(Ping_interval)
 	irc.Logger.Printf("IRC timer started")
 
@@ -319,14 +405,17 @@ func (irc *IRC) connect() error {
 }
 
 func (irc *IRC) disconnect() {
-	if irc.conn != nil {
-		irc.conn.Close()
+	if conn := irc.takeConn(); conn != nil {
+		conn.Close()
 		irc.timer.Stop()
 		irc.timerExCh <- true
 		for ch := range irc.channels {
 			irc.LeaveChannel(ch)
 		}
-		irc.conn = nil
+		irc.resetJoins()
+		irc.resetAccounts()
+		irc.sendq.clear()
+		irc.registered = false
 		irc.State = Disconnected
 		irc.Logger.Println("IRC disconnected")
 	} else {
@@ -339,7 +428,14 @@ func (irc *IRC) disconnect() {
 // IRC internal communications
 func (irc *IRC) register() error {
 	var err error
-	err = irc.sendMsg("PASS " + irc.config.Identify_passwd)
+	// registration is suspended until CAP END is sent,
+	// servers without capability support ignore it
+	irc.caps.reset()
+	irc.isupport.reset()
+	irc.registered = false
+	irc.nickTries = 0
+	irc.setNick(irc.config.BotNick)
+	err = irc.sendMsg("CAP LS 302")
 	if err != nil {
 		return err
 	}
@@ -359,13 +455,13 @@ func (irc *IRC) register() error {
 func (irc *IRC) joinChannels() error {
 	var err error
 	for _, ch := range irc.config.Channels {
-		err = irc.Join(ch.Name)
+		err = irc.joinChannel(ch.Name, ch.Key)
 		if err != nil {
 			irc.Logger.Printf("Failed to join %s: %s",
 				ch.Name, err)
 			return err
 		} else {
-			irc.Logger.Printf("Joined %s",
+			irc.Logger.Printf("Joining %s",
 				ch.Name)
 		}
 	}
@@ -381,8 +477,10 @@ func (irc *IRC) readLoop() {
 	var i int
 	var start int
 	var t time.Time
+	var conn net.Conn
 
-	if irc.State < Connected {
+	conn = irc.getConn()
+	if irc.State < Connected || conn == nil {
 		return
 	}
 
@@ -391,8 +489,11 @@ func (irc *IRC) readLoop() {
 	fraglen = 0
 
 	for {
-		n, err = irc.conn.Read(msg)
+		n, err = conn.Read(msg)
 		t = time.Now()
+		if n > 0 {
+			irc.setLastRecv(t)
+		}
 		irc.rawLogger.Printf("%s\t%s\t%s\t%s",
 			dateTime(t),
 			irc.config.Name,
@@ -425,7 +526,7 @@ func (irc *IRC) readLoop() {
 			irc.Logger.Print("Read error:", err)
 			if !irc.stopping {
 				irc.bot.AddEvent(NewEvent(Disconnect, irc))
-				if irc.config.AutoConnect {
+				if irc.autoReconnect() {
 					defer irc.reconnect()
 				}
 			}
@@ -436,34 +537,110 @@ func (irc *IRC) readLoop() {
 	irc.Logger.Print("IRC read loop done")
 }
 
+// autoReconnect checks if the connection is retried when lost,
+// it is not after the credentials are refused.
+func (irc *IRC) autoReconnect() bool {
+	return irc.config.AutoConnect && irc.authErr == nil
+}
+
+func (irc *IRC) nextServer() *ServerConfig {
+	servers := irc.config.GetServers()
+	return servers[irc.serverIdx%len(servers)]
+}
+
+// backoff returns the wait before the n-th retry, doubling from base
+// up to max, with random jitter of up to half of it.
+func backoff(n int, base, max time.Duration) time.Duration {
+	var d time.Duration
+
+	d = base
+	for i := 1; i < n && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
 func (irc *IRC) reconnect() {
-	irc.conn = nil
-	irc.State = Disconnected
+	// stops the timer of the old connection
+	irc.disconnect()
 	irc.connect()
 }
 
+func (irc *IRC) setLastRecv(t time.Time) {
+	atomic.StoreInt64(&irc.lastRecv, t.UnixNano())
+}
+
+// idle returns the time since the last line from the server.
+func (irc *IRC) idle() time.Duration {
+	return time.Since(time.Unix(0, atomic.LoadInt64(&irc.lastRecv)))
+}
+
+// checkTimeout closes the connection if the server is silent for too long,
+// the read loop then fails and reconnects.
+func (irc *IRC) checkTimeout() {
+	var timeout time.Duration
+
+	timeout = irc.config.GetPingTimeout()
+	if conn := irc.getConn(); conn != nil {
+		if idle := irc.idle(); idle > timeout {
+			irc.Logger.Printf("Ping timeout: no data for %s", idle)
+			conn.Close()
+		}
+	}
+}
+
+// sendMsg queues the line to the server, PONG and QUIT are sent at once.
 func (irc *IRC) sendMsg(msg string) error {
+	if irc.getConn() == nil {
+		return ErrNotConnected
+	}
+	lane, target, urgent := classify(msg)
+	if urgent {
+		return irc.write(msg)
+	}
+	irc.sendq.push(lane, &sendItem{msg, target})
+	return nil
+}
+
+// write sends the line on the connection, the only place writing to it.
+func (irc *IRC) write(msg string) error {
 	var err error
 	var n int
 	var total int
 	var sent int
 	var cmd string
 	var data []byte
+	var conn net.Conn
+
+	irc.writeLock.Lock()
+	defer irc.writeLock.Unlock()
+	// disconnect may clear it meanwhile, the closed one fails the write
+	conn = irc.getConn()
+	if conn == nil {
+		irc.Logger.Printf("Not connected, message dropped: %s", msg)
+		return ErrNotConnected
+	}
 
+	// the line length includes the CRLF
+	if n := irc.isupport.LineLen() - len(crlf); len(msg) > n {
+		irc.Logger.Printf("Message too long, truncated: %s", msg)
+		msg = truncate(msg, n)
+	}
 	cmd = fmt.Sprintf("%s%s", msg, crlf)
 	data = []byte(cmd)
 	total = len(data)
 	sent = 0
 
 	for sent < total {
-		n, err = irc.conn.Write(data[sent:])
+		n, err = conn.Write(data[sent:])
 		if err != nil {
 			irc.Logger.Printf("Failed to send message: %s", msg)
 			irc.Logger.Println(err)
-			irc.bot.AddEvent(NewEvent(Disconnect, irc))
-			if irc.config.AutoConnect {
-				defer irc.reconnect()
-			}
+			// the read loop fails too and handles the reconnection
+			conn.Close()
 			return err
 		}
 
@@ -478,13 +655,36 @@ func (irc *IRC) sendMsg(msg string) error {
 	return nil
 }
 
+func (irc *IRC) getConn() net.Conn {
+	irc.connLock.Lock()
+	defer irc.connLock.Unlock()
+	return irc.conn
+}
+
+func (irc *IRC) setConn(conn net.Conn) {
+	irc.connLock.Lock()
+	irc.conn = conn
+	irc.connLock.Unlock()
+}
+
+// takeConn clears the connection and returns it to be closed.
+func (irc *IRC) takeConn() net.Conn {
+	irc.connLock.Lock()
+	defer irc.connLock.Unlock()
+	conn := irc.conn
+	irc.conn = nil
+	return conn
+}
+
 func (irc *IRC) runTimer() {
 	var stop bool
 
 	for !stop {
 		select {
 		case <-irc.timer.C:
+			irc.checkTimeout()
 			irc.Ping(irc.host)
+			irc.retryNick()
 		case stop = <-irc.timerExCh:
 			break
 		}
@@ -509,12 +709,12 @@ func (irc *IRC) messageLoop() {
 }
 
 func (irc *IRC) commandLoop() {
-	var cmd *IRCCommand
+	var msg *Message
 	var quit bool
 	for !quit {
 		select {
-		case cmd = <-irc.cmdCh:
-			irc.onCommand(cmd.cmd, cmd.prefix, cmd.param)
+		case msg = <-irc.cmdCh:
+			irc.onCommand(msg)
 		case quit = <-irc.cmdExCh:
 			break
 		}
@@ -550,48 +750,49 @@ func (irc *IRC) commandLoop() {
 //}
 
 // IRC message handling
-func (irc *IRC) onMessage(msg string) {
-	var m []string
-
-	m = msgRe.FindStringSubmatch(msg)
-	if m == nil {
-		irc.Logger.Printf("Funky message found: %s", msg)
+func (irc *IRC) onMessage(line string) {
+	msg, err := ParseMessage(line)
+	if err != nil {
+		irc.Logger.Printf("Funky message found: %s", line)
 		return
 	}
-	prefix, cmd, param := m[1], m[2], m[3]
-	// trim tailing space
-	if prefix != "" {
-		prefix = prefix[1 : len(prefix)-1]
-	}
-	if numeric(cmd) {
-		n, err := strconv.Atoi(cmd)
+	if numeric(msg.Command) {
+		n, err := strconv.Atoi(msg.Command)
 		if err != nil {
 			panic(err)
 		}
-		cmd = Numerics[n]
+		if name := Numerics[n]; name != "" {
+			msg.Command = name
+		}
 	}
-	if _, ok := irc.handlers[cmd]; ok {
-		irc.cmdCh <- &IRCCommand{cmd, prefix, param}
+	if _, ok := irc.handlers[msg.Command]; ok {
+		irc.cmdCh <- msg
 	} else {
-		fmt.Printf("%s|%s|%s\n", cmd, prefix, param)
-		irc.Logger.Printf("unhandled message %s", msg)
+		fmt.Printf("%s|%s|%s\n", msg.Command, msg.Source, msg.Params)
+		irc.Logger.Printf("unhandled message %s", line)
 	}
 }
 
-func (irc *IRC) onCommand(cmd, prefix, param string) {
+func (irc *IRC) onCommand(msg *Message) {
 	var proc CommandHandler
 
-	proc = irc.handlers[cmd]
-	proc(prefix, param)
+	proc = irc.handlers[msg.Command]
+	if proc == nil {
+		irc.Logger.Printf("No handler for %s", msg.Command)
+		return
+	}
+	defer irc.bot.recoverPanic(fmt.Sprintf("%s %s", irc, msg.Command), nil)
+	proc(msg)
 }
 
 // end IRC message handling
 
 // Channel management
+// channels are keyed by the name folded with the server casemapping
 func (irc *IRC) GetChannel(ch string) *Channel {
 	var channel *Channel
 	var ok bool
-	if channel, ok = irc.channels[ch]; !ok {
+	if channel, ok = irc.channels[irc.isupport.Fold(ch)]; !ok {
 		return nil
 	}
 	return channel
@@ -599,22 +800,28 @@ func (irc *IRC) GetChannel(ch string) *Channel {
 
 func (irc *IRC) JoinChannel(ch string) *Channel {
 	var channel *Channel
+	var key string
 
-	if channel, ok := irc.channels[ch]; ok {
+	key = irc.isupport.Fold(ch)
+	if channel, ok := irc.channels[key]; ok {
 		return channel
 	}
 
 	channel = NewChannel(irc, ch)
-	irc.channels[ch] = channel
+	irc.channels[key] = channel
 	return channel
 }
 
 func (irc *IRC) LeaveChannel(ch string) *Channel {
 	var channel *Channel
-	if _, ok := irc.channels[ch]; !ok {
+	var key string
+	var ok bool
+
+	key = irc.isupport.Fold(ch)
+	if channel, ok = irc.channels[key]; !ok {
 		return nil
 	}
-	delete(irc.channels, ch)
+	delete(irc.channels, key)
 	return channel
 }
 
@@ -0,0 +1,60 @@
+// Copyright 2016 Alex Fluter
+
+package bot
+
+// Account returns the services account the nick is logged in as,
+// learned from extended-join, account-notify, account-tag and WHOIS,
+// empty if unknown or not logged in.
+func (irc *IRC) Account(nick string) string {
+	irc.accountLock.Lock()
+	defer irc.accountLock.Unlock()
+	return irc.accounts[irc.isupport.Fold(nick)]
+}
+
+func (irc *IRC) setAccount(nick, account string) {
+	irc.accountLock.Lock()
+	defer irc.accountLock.Unlock()
+	if account == "" || account == "*" {
+		delete(irc.accounts, irc.isupport.Fold(nick))
+	} else {
+		irc.accounts[irc.isupport.Fold(nick)] = account
+	}
+}
+
+func (irc *IRC) renameAccount(nick, newNick string) {
+	irc.accountLock.Lock()
+	defer irc.accountLock.Unlock()
+	if account, ok := irc.accounts[irc.isupport.Fold(nick)]; ok {
+		delete(irc.accounts, irc.isupport.Fold(nick))
+		irc.accounts[irc.isupport.Fold(newNick)] = account
+	}
+}
+
+func (irc *IRC) resetAccounts() {
+	irc.accountLock.Lock()
+	defer irc.accountLock.Unlock()
+	irc.accounts = make(map[string]string)
+}
+
+// sharesChannel checks if the nick is in one of the channels I'm in.
+func (irc *IRC) sharesChannel(nick string) bool {
+	for _, ch := range irc.channels {
+		if ch.contains(nick) {
+			return true
+		}
+	}
+	return false
+}
+
+// pruneAccounts forgets the accounts of the nicks not sharing a channel,
+// their QUIT and NICK are not seen any more so the nick may be taken by
+// someone else.
+func (irc *IRC) pruneAccounts() {
+	irc.accountLock.Lock()
+	defer irc.accountLock.Unlock()
+	for key := range irc.accounts {
+		if !irc.sharesChannel(key) {
+			delete(irc.accounts, key)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+// Copyright 2016 Alex Fluter
+
+package bot
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// IRCv3 client capabilities known to the bot
+const (
+	CapServerTime    = "server-time"
+	CapAccountNotify = "account-notify"
+	CapAwayNotify    = "away-notify"
+	CapExtendedJoin  = "extended-join"
+	CapMultiPrefix   = "multi-prefix"
+	CapMessageTags   = "message-tags"
+	CapAccountTag    = "account-tag"
+	CapEchoMessage   = "echo-message"
+	CapSasl          = "sasl"
+)
+
+var (
+	// wantedCaps is a list of capabilities requested during registration.
+	// Modules add to it with RegisterCapability from their init function.
+	wantedCaps []string
+)
+
+// RegisterCapability declares that cap should be requested from the servers.
+func RegisterCapability(cap string) {
+	for _, c := range wantedCaps {
+		if c == cap {
+			return
+		}
+	}
+	wantedCaps = append(wantedCaps, cap)
+}
+
+func init() {
+	// capabilities handled by the IRC module itself
+	RegisterCapability(CapServerTime)
+	RegisterCapability(CapAccountNotify)
+	RegisterCapability(CapAwayNotify)
+	RegisterCapability(CapExtendedJoin)
+	RegisterCapability(CapMultiPrefix)
+	RegisterCapability(CapMessageTags)
+	RegisterCapability(CapAccountTag)
+	RegisterCapability(CapEchoMessage)
+}
+
+// capSet keeps the state of the capability negotiation with a server.
+type capSet struct {
+	sync.Mutex
+	negotiating bool
+	available   map[string]string
+	acked       map[string]bool
+}
+
+func newCapSet() *capSet {
+	c := new(capSet)
+	c.available = make(map[string]string)
+	c.acked = make(map[string]bool)
+	return c
+}
+
+func (c *capSet) reset() {
+	c.Lock()
+	c.negotiating = true
+	c.available = make(map[string]string)
+	c.acked = make(map[string]bool)
+	c.Unlock()
+}
+
+func (c *capSet) has(cap string) bool {
+	c.Lock()
+	defer c.Unlock()
+	return c.acked[cap]
+}
+
+// value returns the value of cap advertised by the server,
+// ok is false if the server does not support it.
+func (c *capSet) value(cap string) (value string, ok bool) {
+	c.Lock()
+	defer c.Unlock()
+	value, ok = c.available[cap]
+	return
+}
+
+func (c *capSet) list() []string {
+	var caps []string
+
+	c.Lock()
+	for cap := range c.acked {
+		caps = append(caps, cap)
+	}
+	c.Unlock()
+	sort.Strings(caps)
+	return caps
+}
+
+// names returns the capabilities advertised by the server.
+func (c *capSet) names() []string {
+	var caps []string
+
+	c.Lock()
+	for cap := range c.available {
+		caps = append(caps, cap)
+	}
+	c.Unlock()
+	sort.Strings(caps)
+	return caps
+}
+
+// add records the capabilities advertised by the server,
+// in the form of name or name=value.
+func (c *capSet) add(caps []string) {
+	c.Lock()
+	for _, cap := range caps {
+		arr := strings.SplitN(cap, "=", 2)
+		if len(arr) == 2 {
+			c.available[arr[0]] = arr[1]
+		} else {
+			c.available[arr[0]] = ""
+		}
+	}
+	c.Unlock()
+}
+
+func (c *capSet) del(caps []string) {
+	c.Lock()
+	for _, cap := range caps {
+		cap = strings.SplitN(cap, "=", 2)[0]
+		delete(c.available, cap)
+		delete(c.acked, cap)
+	}
+	c.Unlock()
+}
+
+func (c *capSet) ack(caps []string) {
+	c.Lock()
+	for _, cap := range caps {
+		if strings.HasPrefix(cap, "-") {
+			delete(c.acked, cap[1:])
+		} else {
+			c.acked[cap] = true
+		}
+	}
+	c.Unlock()
+}
+
+// wanted returns the capabilities in caps that are in want
+// and not acknowledged yet.
+func (c *capSet) wanted(caps []string, want []string) []string {
+	var req []string
+
+	c.Lock()
+	defer c.Unlock()
+	for _, cap := range caps {
+		cap = strings.SplitN(cap, "=", 2)[0]
+		if c.acked[cap] {
+			continue
+		}
+		for _, w := range want {
+			if w == cap {
+				req = append(req, cap)
+				break
+			}
+		}
+	}
+	return req
+}
+
+// finish ends the negotiation, returns true if it was in progress.
+func (c *capSet) finish() bool {
+	c.Lock()
+	defer c.Unlock()
+	if !c.negotiating {
+		return false
+	}
+	c.negotiating = false
+	return true
+}
+
+// HasCap returns true if cap is acknowledged by the server.
+func (irc *IRC) HasCap(cap string) bool {
+	return irc.caps.has(cap)
+}
+
+// Caps returns the list of acknowledged capabilities.
+func (irc *IRC) Caps() []string {
+	return irc.caps.list()
+}
+
+// capsWanted returns the capabilities to request from the server,
+// sasl is only requested when authentication is configured.
+func (irc *IRC) capsWanted() []string {
+	want := wantedCaps
+	if irc.saslEnabled() && irc.saslSupported() {
+		want = append(want[:len(want):len(want)], CapSasl)
+	}
+	return want
+}
+
+func (irc *IRC) capRequest(caps []string) error {
+	req := irc.caps.wanted(caps, irc.capsWanted())
+	if len(req) == 0 {
+		return irc.capEnd()
+	}
+	return irc.sendMsg("CAP REQ :" + strings.Join(req, " "))
+}
+
+func (irc *IRC) capEnd() error {
+	if !irc.caps.finish() {
+		return nil
+	}
+	return irc.sendMsg("CAP END")
+}
+
+// format:
+// :server CAP * LS * :multi-prefix extended-join sasl=PLAIN,EXTERNAL
+// :server CAP * LS :account-notify away-notify
+// :server CAP candice ACK :multi-prefix extended-join
+// :server CAP candice NEW :batch
+func (irc *IRC) onCap(msg *Message) {
+	var sub string
+	var more bool
+	var caps []string
+
+	if len(msg.Params) < 2 {
+		irc.Logger.Printf("Invalid CAP message: %s", msg)
+		return
+	}
+	sub = strings.ToUpper(msg.Param(1))
+	more = len(msg.Params) > 3 && msg.Param(2) == "*"
+	if len(msg.Params) > 2 {
+		caps = strings.Fields(msg.Trailing())
+	}
+
+	switch sub {
+	case "LS":
+		irc.caps.add(caps)
+		if more {
+			return
+		}
+		all := irc.caps.names()
+		irc.Logger.Printf("Server capabilities: %s", strings.Join(all, " "))
+		irc.capRequest(all)
+	case "ACK":
+		irc.caps.ack(caps)
+		irc.Logger.Printf("Capabilities acknowledged: %s", strings.Join(caps, " "))
+		if !more && !irc.saslStart(caps) {
+			irc.capEnd()
+		}
+	case "NAK":
+		irc.Logger.Printf("Capabilities rejected: %s", strings.Join(caps, " "))
+		if !more {
+			irc.capEnd()
+		}
+	case "NEW":
+		irc.caps.add(caps)
+		irc.Logger.Printf("New capabilities: %s", strings.Join(caps, " "))
+		if req := irc.caps.wanted(caps, wantedCaps); len(req) > 0 {
+			irc.sendMsg("CAP REQ :" + strings.Join(req, " "))
+		}
+	case "DEL":
+		irc.caps.del(caps)
+		irc.Logger.Printf("Capabilities removed: %s", strings.Join(caps, " "))
+	case "LIST":
+		irc.Logger.Printf("Enabled capabilities: %s", strings.Join(caps, " "))
+	default:
+		irc.Logger.Printf("Unknown CAP subcommand %s: %s", sub, msg)
+	}
+}
@@ -0,0 +1,82 @@
+package bot
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readLine(r net.Conn) string {
+	buf := make([]byte, 1024)
+	n, _ := r.Read(buf)
+	return string(buf[:n])
+}
+
+func TestCapNegotiation(t *testing.T) {
+	ch := make(chan bool)
+	bot := newTestBot(ch)
+
+	irc := testIRC(bot, t)
+	r := testConn(irc)
+	irc.caps.reset()
+
+	irc.onCommand(command("CAP", "server", "* LS * :multi-prefix sasl=PLAIN"))
+	go irc.onCommand(command("CAP", "server", "* LS :foo extended-join"))
+	if s := readLine(r); s != "CAP REQ :extended-join multi-prefix\r\n" {
+		t.Error(s)
+	}
+
+	go irc.onCommand(command("CAP", "server", G+" ACK :extended-join multi-prefix"))
+	if s := readLine(r); s != "CAP END\r\n" {
+		t.Error(s)
+	}
+	if !irc.HasCap(CapMultiPrefix) || !irc.HasCap(CapExtendedJoin) {
+		t.Error(irc.Caps())
+	}
+	if irc.HasCap("sasl") || irc.HasCap("foo") {
+		t.Error(irc.Caps())
+	}
+
+	irc.onCommand(command("CAP", "server", G+" DEL :multi-prefix"))
+	if irc.HasCap(CapMultiPrefix) {
+		t.Error(irc.Caps())
+	}
+
+	irc.conn = nil
+	delTestBot(bot, t, ch)
+}
+
+func TestEchoMessage(t *testing.T) {
+	ch := make(chan bool)
+	bot := newTestBot(ch)
+
+	irc := testIRC(bot, t)
+	r := testConn(irc)
+	irc.caps.reset()
+	irc.setNick(G)
+
+	go irc.onCommand(command("CAP", "server", "* LS :echo-message"))
+	if s := readLine(r); s != "CAP REQ :echo-message\r\n" {
+		t.Error(s)
+	}
+	go irc.onCommand(command("CAP", "server", G+" ACK :echo-message"))
+	if s := readLine(r); s != "CAP END\r\n" {
+		t.Error(s)
+	}
+
+	// our own message is logged, not handled as a command
+	irc.onCommand(command("PRIVMSG", G+"!~u@host", "#candice :?version"))
+	r.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	if s := readLine(r); s != "" {
+		t.Error(s)
+	}
+	r.SetReadDeadline(time.Time{})
+	irc.onCommand(command("PRIVMSG", "foo!~u@host", "#candice :?version"))
+	if s := readLine(r); !strings.HasPrefix(s, "PRIVMSG #candice :") {
+		t.Error(s)
+	}
+
+	irc.conn = nil
+	delTestBot(bot, t, ch)
+}
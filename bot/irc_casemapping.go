@@ -0,0 +1,65 @@
+// Copyright 2016 Alex Fluter
+
+package bot
+
+import (
+	"strings"
+)
+
+// case mappings advertised in CASEMAPPING
+const (
+	CaseMappingASCII         = "ascii"
+	CaseMappingRFC1459       = "rfc1459"
+	CaseMappingStrictRFC1459 = "strict-rfc1459"
+)
+
+// rfc1459 treats {}|^ as the lower case of []\~,
+// strict-rfc1459 does the same except for ^ and ~.
+var (
+	rfc1459Folder       = strings.NewReplacer("[", "{", "]", "}", "\\", "|", "~", "^")
+	strictRFC1459Folder = strings.NewReplacer("[", "{", "]", "}", "\\", "|")
+)
+
+// CaseFold returns the lower case form of name under the casemapping,
+// unknown casemappings are treated as ascii.
+func CaseFold(casemapping, name string) string {
+	name = toLowerASCII(name)
+	switch casemapping {
+	case CaseMappingRFC1459:
+		name = rfc1459Folder.Replace(name)
+	case CaseMappingStrictRFC1459:
+		name = strictRFC1459Folder.Replace(name)
+	}
+	return name
+}
+
+// toLowerASCII maps only A-Z, non ascii letters are left untouched.
+func toLowerASCII(s string) string {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 'A' && s[i] <= 'Z' {
+			buf := []byte(s)
+			for ; i < len(buf); i++ {
+				if buf[i] >= 'A' && buf[i] <= 'Z' {
+					buf[i] += 'a' - 'A'
+				}
+			}
+			return string(buf)
+		}
+	}
+	return s
+}
+
+// Fold returns the key of a nick or channel name under the server casemapping.
+func (s *ISupport) Fold(name string) string {
+	return CaseFold(s.CaseMapping(), name)
+}
+
+// Equal compares nicks or channel names under the server casemapping.
+func (s *ISupport) Equal(a, b string) bool {
+	return s.Fold(a) == s.Fold(b)
+}
+
+// IsMe returns true if nick is the nick of the bot.
+func (irc *IRC) IsMe(nick string) bool {
+	return irc.isupport.Equal(nick, irc.CurrentNick())
+}
@@ -0,0 +1,52 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCaseFold(t *testing.T) {
+	cases := []struct {
+		casemapping, name, folded string
+	}{
+		{CaseMappingASCII, "#Go[]~", "#go[]~"},
+		{CaseMappingRFC1459, "Foo[]\\~", "foo{}|^"},
+		{CaseMappingStrictRFC1459, "Foo[]\\~", "foo{}|~"},
+		{"unknown", "ÄBC", "Äbc"},
+	}
+	for _, c := range cases {
+		if s := CaseFold(c.casemapping, c.name); s != c.folded {
+			t.Errorf("%s %s: %s", c.casemapping, c.name, s)
+		}
+	}
+}
+
+func TestCaseMappingChannels(t *testing.T) {
+	ch := make(chan bool)
+	bot := newTestBot(ch)
+
+	irc := testIRC(bot, t)
+
+	me := strings.ToLower(G)
+	irc.onCommand(command("JOIN", me+"!~u@host", "#Go"))
+	irc.onCommand(command("JOIN", "Foo[]!~u@host", "#go"))
+	channel := irc.GetChannel("#GO")
+	if channel == nil {
+		t.Fatal(irc.channels)
+	}
+	if !channel.contains(G) || !channel.contains("foo{}") {
+		t.Error(channel)
+	}
+
+	irc.onCommand(command("NICK", "FOO{]!~u@host", "Bar"))
+	if channel.contains("foo[]") || !channel.contains("BAR") {
+		t.Error(channel)
+	}
+
+	irc.onCommand(command("PART", strings.ToUpper(G)+"!~u@host", "#gO"))
+	if irc.GetChannel("#go") != nil {
+		t.Error(irc.channels)
+	}
+
+	delTestBot(bot, t, ch)
+}
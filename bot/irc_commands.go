@@ -9,6 +9,9 @@ import (
 // IRC commands, defined by RFC 2812
 
 func (irc *IRC) Nick(nick string) error {
+	if n := irc.isupport.NickLen(); len(nick) > n {
+		irc.Logger.Printf("Nick %s is longer than %d", nick, n)
+	}
 	msg := fmt.Sprintf("NICK %s", nick)
 	return irc.sendMsg(msg)
 }
@@ -44,10 +47,19 @@ func (irc *IRC) Join(channel string) error {
 	return irc.sendMsg(msg)
 }
 
+// JoinKey joins a channel with key, the key is omitted if empty.
+func (irc *IRC) JoinKey(channel, key string) error {
+	if key == "" {
+		return irc.Join(channel)
+	}
+	msg := fmt.Sprintf("JOIN %s %s", channel, key)
+	return irc.sendMsg(msg)
+}
+
 func (irc *IRC) Part(channel, partMsg string) error {
 	var msg string
 	if partMsg != "" {
-		msg = fmt.Sprintf("PART %s %s", channel, partMsg)
+		msg = fmt.Sprintf("PART %s :%s", channel, partMsg)
 	} else {
 		msg = fmt.Sprintf("PART %s", channel)
 	}
@@ -63,6 +75,9 @@ func (irc *IRC) GetTopic(channel string) error {
 }
 
 func (irc *IRC) SetTopic(channel, topic string) error {
+	if n := irc.isupport.TopicLen(); n > 0 {
+		topic = truncate(topic, n)
+	}
 	msg := fmt.Sprintf("TOPIC %s :%s", channel, topic)
 	return irc.sendMsg(msg)
 }
@@ -82,29 +97,23 @@ func (irc *IRC) Invite(nick, channel string) error {
 
 // kick
 
+// Privmsg sends msg to the target, long messages are split into lines.
 func (irc *IRC) Privmsg(to, msg string) error {
-	var line string
-
-	if IsChannel(to) {
+	// with echo-message the server sends it back to be logged
+	if irc.IsChannel(to) && !irc.HasCap(CapEchoMessage) {
 		if ch := irc.GetChannel(to); ch != nil {
-			ch.onPrivmsg(irc.config.BotNick, msg)
+			ch.onPrivmsg(irc.CurrentNick(), msg)
 		}
 	}
 
 	if irc.config.DebugMode {
-		line = fmt.Sprintf("PRIVMSG %s :%s :%s", irc.config.RedirectTo, to, msg)
-	} else {
-		line = fmt.Sprintf("PRIVMSG %s :%s", to, msg)
+		return irc.sendText("PRIVMSG", to, irc.config.RedirectTo, to+" :", msg)
 	}
-	return irc.sendMsg(line)
+	return irc.sendText("PRIVMSG", to, to, "", msg)
 }
 
 func (irc *IRC) Notice(to, msg string) error {
-	var line string
-
-	line = fmt.Sprintf("NOTICE %s :%s", to, msg)
-
-	return irc.sendMsg(line)
+	return irc.sendText("NOTICE", to, to, "", msg)
 }
 
 // server query
@@ -0,0 +1,138 @@
+package bot
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	base, max := 5*time.Second, time.Minute
+	for _, c := range []struct {
+		n        int
+		min, max time.Duration
+	}{
+		{1, 2500 * time.Millisecond, 5 * time.Second},
+		{2, 5 * time.Second, 10 * time.Second},
+		{4, 20 * time.Second, 40 * time.Second},
+		{10, 30 * time.Second, time.Minute},
+	} {
+		for i := 0; i < 10; i++ {
+			if d := backoff(c.n, base, max); d < c.min || d > c.max {
+				t.Error(c.n, d)
+			}
+		}
+	}
+}
+
+func TestServerList(t *testing.T) {
+	config := &IRCConfig{Server: "irc.example.com", Port: 6667}
+	if servers := config.GetServers(); len(servers) != 1 || servers[0].String() != "irc.example.com:6667" {
+		t.Error(servers)
+	}
+
+	config.Servers = []*ServerConfig{
+		&ServerConfig{Host: "a.example.com", Port: 6697, Ssl: true},
+		&ServerConfig{Host: "b.example.com", Port: 6667},
+	}
+	irc := &IRC{config: config}
+	for i, host := range []string{"a.example.com", "b.example.com", "a.example.com"} {
+		if s := irc.nextServer(); s.Host != host {
+			t.Error(i, s)
+		}
+		irc.serverIdx++
+	}
+	if config.GetReconnectMax() != DefaultReconnectMax {
+		t.Error(config.GetReconnectMax())
+	}
+}
+
+func TestPingTimeout(t *testing.T) {
+	ch := make(chan bool)
+	bot := newTestBot(ch)
+
+	irc := testIRC(bot, t)
+	r := testConn(irc)
+	irc.config.PingTimeout = 60
+	if irc.config.GetPingTimeout() != MinPingTimeout {
+		t.Error(irc.config.GetPingTimeout())
+	}
+	irc.config.PingTimeout = 150
+
+	irc.setLastRecv(time.Now().Add(-30 * time.Second))
+	irc.checkTimeout()
+	r.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+	if _, err := r.Read(make([]byte, 1)); err == io.EOF {
+		t.Error("closed before timeout")
+	}
+	if !strings.Contains(irc.Status(), "Last activity: 30s ago") {
+		t.Error(irc.Status())
+	}
+
+	irc.setLastRecv(time.Now().Add(-3 * time.Minute))
+	irc.checkTimeout()
+	r.SetReadDeadline(time.Time{})
+	if _, err := r.Read(make([]byte, 1)); err != io.EOF {
+		t.Error(err)
+	}
+
+	irc.conn = nil
+	irc.config.PingTimeout = 0
+	delTestBot(bot, t, ch)
+}
+
+func TestWriteDisconnect(t *testing.T) {
+	ch := make(chan bool)
+	bot := newTestBot(ch)
+	irc := testIRC(bot, t)
+	r := testConn(irc)
+	go io.Copy(io.Discard, r)
+
+	// the connection is cleared while the writer is sending
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 100; i++ {
+			irc.write("PING :x")
+		}
+		done <- true
+	}()
+	time.Sleep(time.Millisecond)
+	if conn := irc.takeConn(); conn != nil {
+		conn.Close()
+	}
+	<-done
+	if err := irc.write("PING :x"); err != ErrNotConnected {
+		t.Error(err)
+	}
+	delTestBot(bot, t, ch)
+}
+
+func TestConnectStop(t *testing.T) {
+	ch := make(chan bool)
+	bot := newTestBot(ch)
+	irc := testIRC(bot, t)
+
+	// nothing listens on port 0, the attempt fails and waits to retry
+	stop := make(chan bool)
+	irc.stopCh = stop
+	irc.State = Disconnected
+	done := make(chan error)
+	go func() {
+		done <- irc.connect()
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(stop)
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("connected")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the wait is not interrupted")
+	}
+
+	irc.stopCh = make(chan bool)
+	irc.State = Running
+	delTestBot(bot, t, ch)
+}
@@ -0,0 +1,227 @@
+// Copyright 2016 Alex Fluter
+
+package bot
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	dialTimeout = 30 * time.Second
+
+	socks5Version  = 5
+	socks5NoAuth   = 0
+	socks5UserPass = 2
+	socks5Connect  = 1
+	socks5IPv4     = 1
+	socks5Domain   = 3
+	socks5IPv6     = 4
+)
+
+var (
+	ErrProxyScheme  = errors.New("Unsupported proxy scheme")
+	ErrProxyAuth    = errors.New("Proxy authentication failed")
+	ErrProxyConnect = errors.New("Proxy failed to connect")
+)
+
+// dial connects to addr with the configured address family, local address
+// and proxy, the proxy is one of socks5://[user:pass@]host:port
+// and http://[user:pass@]host:port.
+func (irc *IRC) dial(addr string) (net.Conn, error) {
+	var err error
+	var network string
+	var dialer *net.Dialer
+	var proxy *url.URL
+	var conn net.Conn
+
+	network = irc.config.GetNetwork()
+	dialer = &net.Dialer{Timeout: dialTimeout}
+	if irc.config.BindAddr != "" {
+		var laddr *net.TCPAddr
+		laddr, err = net.ResolveTCPAddr(network,
+			net.JoinHostPort(irc.config.BindAddr, "0"))
+		if err != nil {
+			return nil, err
+		}
+		dialer.LocalAddr = laddr
+	}
+
+	if irc.config.Proxy == "" {
+		return dialer.Dial(network, addr)
+	}
+
+	proxy, err = url.Parse(irc.config.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	irc.Logger.Printf("Connecting through %s proxy %s", proxy.Scheme, proxy.Host)
+	conn, err = dialer.Dial(network, proxy.Host)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(dialTimeout))
+	switch strings.ToLower(proxy.Scheme) {
+	case "socks5", "socks5h":
+		err = socks5Handshake(conn, addr, proxy.User)
+	case "http":
+		err = httpConnect(conn, addr, proxy.User)
+	default:
+		err = ErrProxyScheme
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// socks5Handshake asks the SOCKS5 proxy on conn to connect to addr, RFC 1928.
+func socks5Handshake(conn net.Conn, addr string, user *url.Userinfo) error {
+	var err error
+	var buf []byte
+
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return err
+	}
+
+	if user != nil {
+		buf = []byte{socks5Version, 2, socks5NoAuth, socks5UserPass}
+	} else {
+		buf = []byte{socks5Version, 1, socks5NoAuth}
+	}
+	if _, err = conn.Write(buf); err != nil {
+		return err
+	}
+	buf = make([]byte, 2)
+	if _, err = io.ReadFull(conn, buf); err != nil {
+		return err
+	}
+	if buf[0] != socks5Version {
+		return ErrProxyConnect
+	}
+	switch buf[1] {
+	case socks5NoAuth:
+	case socks5UserPass:
+		// RFC 1929
+		if user == nil {
+			return ErrProxyAuth
+		}
+		pass, _ := user.Password()
+		buf = []byte{1, byte(len(user.Username()))}
+		buf = append(buf, user.Username()...)
+		buf = append(buf, byte(len(pass)))
+		buf = append(buf, pass...)
+		if _, err = conn.Write(buf); err != nil {
+			return err
+		}
+		buf = make([]byte, 2)
+		if _, err = io.ReadFull(conn, buf); err != nil {
+			return err
+		}
+		if buf[1] != 0 {
+			return ErrProxyAuth
+		}
+	default:
+		return ErrProxyAuth
+	}
+
+	buf = []byte{socks5Version, socks5Connect, 0}
+	if ip := net.ParseIP(host); ip == nil {
+		buf = append(buf, socks5Domain, byte(len(host)))
+		buf = append(buf, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		buf = append(buf, socks5IPv4)
+		buf = append(buf, ip4...)
+	} else {
+		buf = append(buf, socks5IPv6)
+		buf = append(buf, ip.To16()...)
+	}
+	buf = append(buf, byte(port>>8), byte(port))
+	if _, err = conn.Write(buf); err != nil {
+		return err
+	}
+
+	// VER REP RSV ATYP BND.ADDR BND.PORT
+	buf = make([]byte, 4)
+	if _, err = io.ReadFull(conn, buf); err != nil {
+		return err
+	}
+	if buf[1] != 0 {
+		return fmt.Errorf("%s: SOCKS5 reply %d", ErrProxyConnect, buf[1])
+	}
+	var n int
+	switch buf[3] {
+	case socks5IPv4:
+		n = net.IPv4len
+	case socks5IPv6:
+		n = net.IPv6len
+	case socks5Domain:
+		b := make([]byte, 1)
+		if _, err = io.ReadFull(conn, b); err != nil {
+			return err
+		}
+		n = int(b[0])
+	default:
+		return ErrProxyConnect
+	}
+	// the bound address and port are not used
+	buf = make([]byte, n+2)
+	_, err = io.ReadFull(conn, buf)
+	return err
+}
+
+// httpConnect asks the HTTP proxy on conn to tunnel to addr.
+func httpConnect(conn net.Conn, addr string, user *url.Userinfo) error {
+	var err error
+	var req string
+	var resp []byte
+
+	req = fmt.Sprintf("CONNECT %s HTTP/1.1\r\nHost: %s\r\n", addr, addr)
+	if user != nil {
+		pass, _ := user.Password()
+		auth := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + pass))
+		req += "Proxy-Authorization: Basic " + auth + "\r\n"
+	}
+	req += "\r\n"
+	if _, err = conn.Write([]byte(req)); err != nil {
+		return err
+	}
+
+	// read byte by byte, the server may send data right after the headers
+	b := make([]byte, 1)
+	for !strings.HasSuffix(string(resp), "\r\n\r\n") {
+		if _, err = conn.Read(b); err != nil {
+			return err
+		}
+		resp = append(resp, b[0])
+		if len(resp) > 4096 {
+			return ErrProxyConnect
+		}
+	}
+	// HTTP/1.1 200 Connection established
+	status := strings.Fields(strings.SplitN(string(resp), "\r\n", 2)[0])
+	if len(status) < 2 || !strings.HasPrefix(status[0], "HTTP/") {
+		return ErrProxyConnect
+	}
+	switch status[1] {
+	case "200":
+		return nil
+	case "407":
+		return ErrProxyAuth
+	}
+	return fmt.Errorf("%s: %s", ErrProxyConnect, strings.Join(status[1:], " "))
+}
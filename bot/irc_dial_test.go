@@ -0,0 +1,154 @@
+package bot
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+// ircStandIn accepts connections and greets like a server.
+func ircStandIn(t *testing.T) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			c.Write([]byte(":server NOTICE * :hello\r\n"))
+			c.Close()
+		}
+	}()
+	return l
+}
+
+func tunnel(c net.Conn, addr string) {
+	s, err := net.Dial("tcp", addr)
+	if err != nil {
+		c.Close()
+		return
+	}
+	go io.Copy(s, c)
+	io.Copy(c, s)
+	c.Close()
+	s.Close()
+}
+
+// socks5StandIn is a SOCKS5 proxy accepting user u with password p.
+func socks5StandIn(t *testing.T) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			buf := make([]byte, 512)
+			io.ReadFull(c, buf[:2])
+			io.ReadFull(c, buf[:buf[1]])
+			c.Write([]byte{5, 2})
+			io.ReadFull(c, buf[:2])
+			user := make([]byte, buf[1])
+			io.ReadFull(c, user)
+			io.ReadFull(c, buf[:1])
+			pass := make([]byte, buf[0])
+			io.ReadFull(c, pass)
+			if string(user) != "u" || string(pass) != "p" {
+				c.Write([]byte{1, 1})
+				c.Close()
+				continue
+			}
+			c.Write([]byte{1, 0})
+			io.ReadFull(c, buf[:5])
+			host := make([]byte, buf[4])
+			io.ReadFull(c, host)
+			io.ReadFull(c, buf[:2])
+			port := int(buf[0])<<8 | int(buf[1])
+			c.Write([]byte{5, 0, 0, 1, 127, 0, 0, 1, 0, 0})
+			go tunnel(c, net.JoinHostPort(string(host), strconv.Itoa(port)))
+		}
+	}()
+	return l
+}
+
+// httpStandIn is a HTTP proxy accepting user u with password p.
+func httpStandIn(t *testing.T) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			req, err := http.ReadRequest(bufio.NewReader(c))
+			if err != nil || req.Method != "CONNECT" {
+				c.Close()
+				continue
+			}
+			if req.Header.Get("Proxy-Authorization") != "Basic dTpw" {
+				c.Write([]byte("HTTP/1.1 407 Proxy Authentication Required\r\n\r\n"))
+				c.Close()
+				continue
+			}
+			c.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+			go tunnel(c, req.Host)
+		}
+	}()
+	return l
+}
+
+func TestDial(t *testing.T) {
+	server := ircStandIn(t)
+	defer server.Close()
+	socks := socks5StandIn(t)
+	defer socks.Close()
+	proxy := httpStandIn(t)
+	defer proxy.Close()
+
+	addr := "localhost:" + strconv.Itoa(server.Addr().(*net.TCPAddr).Port)
+	irc := &IRC{config: &IRCConfig{}}
+	irc.Logger = NewLoggerFunc("")
+
+	for _, c := range []struct {
+		proxy string
+		err   error
+	}{
+		{"", nil},
+		{"socks5://u:p@" + socks.Addr().String(), nil},
+		{"socks5://u:x@" + socks.Addr().String(), ErrProxyAuth},
+		{"http://u:p@" + proxy.Addr().String(), nil},
+		{"http://" + proxy.Addr().String(), ErrProxyAuth},
+		{"ftp://" + proxy.Addr().String(), ErrProxyScheme},
+	} {
+		irc.config.Proxy = c.proxy
+		irc.config.BindAddr = "127.0.0.1"
+		irc.config.AddressFamily = "ipv4"
+		conn, err := irc.dial(addr)
+		if err != c.err {
+			t.Error(c.proxy, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if s := readLine(conn); s != ":server NOTICE * :hello\r\n" {
+			t.Error(c.proxy, s)
+		}
+		if ip := conn.LocalAddr().(*net.TCPAddr).IP; !ip.Equal(net.IPv4(127, 0, 0, 1)) {
+			t.Error(ip)
+		}
+		conn.Close()
+	}
+}
@@ -5,122 +5,120 @@ package bot
 import (
 	"fmt"
 	"strconv"
-	"strings"
 )
 
-func matchNickUserHost(target string) (string, string, string) {
-	var nick, user, host string
-	m := nickRe.FindStringSubmatch(target)
-	if m != nil {
-		nick, user, host = m[1], m[2], m[3]
-	} else {
-		nick = target
-	}
-	return nick, user, host
-}
-
-func (irc *IRC) getReplyBySpace(param string) string {
-	var arr []string
-
-	arr = strings.SplitN(param, " ", 2)
-	return arr[1]
-}
-
-func (irc *IRC) getReplyByColon(param string) string {
-	var arr []string
-
-	arr = strings.SplitN(param, ":", 2)
-	return arr[1]
-}
-
 // IRC event handlers
 
-func (irc *IRC) onPing(prefix, param string) {
-	irc.Pong(param)
+func (irc *IRC) onPing(msg *Message) {
+	irc.Pong(msg.Trailing())
 }
 
 // format: :sinisalo.freenode.net PONG sinisalo.freenode.net :chat.freenode.net
-func (irc *IRC) onPong(prefix, param string) {
+func (irc *IRC) onPong(msg *Message) {
 	var from, origin string
-	arr := strings.SplitN(param, " ", 2)
-	from = arr[0]
-	origin = arr[1][1:]
+
+	from, origin = msg.Param(0), msg.Param(1)
 
 	irc.bot.AddEvent(NewEvent(Pong,
 		&PongData{irc.bot, irc, from, origin}))
 }
 
-func (irc *IRC) onJoin(from, cha string) {
+// format:
+// :fluter!~fluter@unaffiliated/fluter JOIN #candice
+// extended-join:
+// :fluter!~fluter@unaffiliated/fluter JOIN #candice fluter :Real Name
+func (irc *IRC) onJoin(msg *Message) {
 	var nick, user, host string
+	var cha, account string
 	var ch *Channel
 
-	nick, user, host = matchNickUserHost(from)
+	nick, user, host = msg.Nick, msg.User, msg.Host
+	cha = msg.Param(0)
+	if cha == "" {
+		irc.Logger.Printf("Invalid JOIN message: %s", msg)
+		return
+	}
+	if irc.HasCap(CapExtendedJoin) {
+		if msg.Param(1) != "*" {
+			account = msg.Param(1)
+		}
+		irc.setAccount(nick, account)
+	}
 
 	// confirm of channel join from server
-	if nick == irc.config.BotNick {
+	if irc.IsMe(nick) {
+		irc.setSource(msg.Source)
 		irc.Logger.Println("New channel:", cha)
 		ch = irc.JoinChannel(cha)
+		irc.setJoined(cha)
 		ch.Start(nick)
+		ch.requestModes()
 	}
 
 	// other users joined the channel I'm in
 	ch = irc.GetChannel(cha)
-	ch.onJoin(from)
+	if ch != nil {
+		ch.onJoin(nick, msg.Source)
+	}
 
 	irc.bot.AddEvent(
 		NewEvent(
 			UserJoin,
 			&UserJoinData{
-				EventBase{irc.bot, from, nick, user, host},
-				irc, cha}))
+				EventBase{irc.bot, msg.Source, nick, user, host},
+				irc, cha, account}))
 }
 
-func (irc *IRC) onPart(from, param string) {
+// format: :fluter!~fluter@unaffiliated/fluter PART #candice :bye
+func (irc *IRC) onPart(msg *Message) {
 	var nick, user, host string
-	var arr []string
 	var chn string
 	var partMsg string
 
-	nick, user, host = matchNickUserHost(from)
-
-	arr = strings.SplitN(param, ":", 2)
-	chn = strings.TrimSpace(arr[0])
-	if len(arr) == 2 {
-		partMsg = arr[1]
-	} else {
-		partMsg = ""
-	}
+	nick, user, host = msg.Nick, msg.User, msg.Host
+	chn, partMsg = msg.Param(0), msg.Param(1)
 
 	var ch *Channel
 
 	ch = irc.GetChannel(chn)
-	ch.onPart(from, partMsg)
+	if ch != nil {
+		ch.onPart(nick, msg.Source, partMsg)
+	}
 
-	if nick == irc.config.BotNick {
+	if irc.IsMe(nick) {
 		irc.Logger.Println("Leaving channel:", chn)
-		ch = irc.LeaveChannel(chn)
-		ch.Stop()
+		irc.forgetJoin(chn)
+		if ch = irc.LeaveChannel(chn); ch != nil {
+			ch.Stop()
+		}
 	}
+	irc.pruneAccounts()
 
 	irc.bot.AddEvent(
 		NewEvent(
 			UserPart,
 			&UserPartData{
-				EventBase{irc.bot, from, nick, user, host},
+				EventBase{irc.bot, msg.Source, nick, user, host},
 				irc,
 				chn, partMsg}))
 }
 
-func (irc *IRC) onQuit(from, param string) {
+// format: :fluter!~fluter@unaffiliated/fluter QUIT :Quit: leaving
+func (irc *IRC) onQuit(msg *Message) {
 	var nick, user, host string
 	var ch *Channel
-	var quitMsg string = param[1:]
+	var quitMsg string
 
-	nick, user, host = matchNickUserHost(from)
+	nick, user, host = msg.Nick, msg.User, msg.Host
+	quitMsg = msg.Param(0)
+	irc.setAccount(nick, "")
+	if !irc.IsMe(nick) && irc.isupport.Equal(nick, irc.config.BotNick) {
+		defer irc.recoverNick()
+	}
 
 	for _, ch = range irc.channels {
-		ch.onQuit(nick, from, param)
-		if nick == irc.config.BotNick {
+		ch.onQuit(nick, msg.Source, quitMsg)
+		if irc.IsMe(nick) {
 			irc.Logger.Println("Leaving channel:", ch.name)
 			irc.LeaveChannel(ch.name)
 			ch.Stop()
@@ -131,18 +129,32 @@ func (irc *IRC) onQuit(from, param string) {
 		NewEvent(
 			UserQuit,
 			&UserQuitData{
-				EventBase{irc.bot, from, nick, user, host},
+				EventBase{irc.bot, msg.Source, nick, user, host},
 				irc,
 				quitMsg}))
 }
 
-func (irc *IRC) onNick(from, param string) {
+// format: :fluter!~fluter@unaffiliated/fluter NICK :fluter_
+func (irc *IRC) onNick(msg *Message) {
 	var nick, user, host string
 	var newNick string
 
-	newNick = param[1:]
+	nick, user, host = msg.Nick, msg.User, msg.Host
+	newNick = msg.Param(0)
+	if newNick == "" {
+		irc.Logger.Printf("Invalid NICK message: %s", msg)
+		return
+	}
+
+	if irc.IsMe(nick) {
+		irc.Logger.Printf("Nick changed to %s", newNick)
+		irc.setNick(newNick)
+	} else if irc.isupport.Equal(nick, irc.config.BotNick) {
+		// someone released the primary nick
+		defer irc.recoverNick()
+	}
 
-	nick, user, host = matchNickUserHost(from)
+	irc.renameAccount(nick, newNick)
 
 	var ch *Channel
 	for _, ch = range irc.channels {
@@ -153,271 +165,265 @@ func (irc *IRC) onNick(from, param string) {
 		NewEvent(
 			UserNick,
 			&UserNickData{
-				EventBase{irc.bot, from, nick, user, host},
+				EventBase{irc.bot, msg.Source, nick, user, host},
 				irc,
 				newNick}))
 }
 
-func (irc *IRC) onInvite(from, param string) {
-	var nick string
+// format: :fluter!~fluter@unaffiliated/fluter INVITE candice :#candice
+func (irc *IRC) onInvite(msg *Message) {
 	var me string
 	var channel string
 
-	nick, _, _ = matchNickUserHost(from)
-	channel = irc.getReplyBySpace(param)
-	me = strings.SplitN(param, " ", 2)[0]
+	me, channel = msg.Param(0), msg.Param(1)
 
-	if me != irc.config.BotNick {
+	if !irc.IsMe(me) {
 		// suspicous invite message not directing to me
 		panic(me)
 	}
 
-	irc.Logger.Printf("%s is inviting me to join %s", nick, channel)
-	irc.Join(channel)
+	irc.Logger.Printf("%s is inviting me to join %s", msg.Nick, channel)
+	irc.joinChannel(channel, irc.config.ChannelKey(channel))
 }
 
-func (irc *IRC) onPrivmsg(from, param string) {
-	//    var reply string
+// format: :fluter!~fluter@unaffiliated/fluter PRIVMSG #candice :hello
+func (irc *IRC) onPrivmsg(msg *Message) {
 	var nick, user, host string
 	var to string
-	var msg string
-	var i int
+	var text string
 
-	nick, user, host = matchNickUserHost(from)
+	nick, user, host = msg.Nick, msg.User, msg.Host
+	to, text = msg.Param(0), msg.Param(1)
+	if to == "" {
+		irc.Logger.Printf("Invalid PRIVMSG message: %s", msg)
+		return
+	}
 
-	for i = 0; i < len(param)-2; i++ {
-		if param[i] == ' ' && param[i+1] == ':' {
-			to = param[:i]
-			msg = param[i+2:]
-			break
+	// message to channel members with status, e.g. @#candice
+	to = irc.isupport.TrimStatusMsg(to)
+	if irc.HasCap(CapAccountTag) {
+		// no tag means not logged in
+		account, _ := msg.Tag("account")
+		irc.setAccount(nick, account)
+	}
+
+	// our own message echoed back by echo-message
+	if irc.IsMe(nick) && irc.HasCap(CapEchoMessage) {
+		if irc.IsChannel(to) {
+			if ch := irc.GetChannel(to); ch != nil {
+				ch.onPrivmsg(nick, text)
+			}
 		}
+		return
 	}
 
-	if IsChannel(to) {
+	if irc.IsChannel(to) {
 		// get channel
 		// send message to channel
 		if ch := irc.GetChannel(to); ch != nil {
-			ch.onPrivmsg(nick, msg)
+			ch.onPrivmsg(nick, text)
 		}
 
 		irc.bot.AddEvent(NewEvent(ChannelMessage,
 			&ChannelMessageData{
 				PrivateMessageData{
-					EventBase{irc.bot, from, nick, user, host},
+					EventBase{irc.bot, msg.Source, nick, user, host},
 					irc,
-					msg}, to}))
+					text}, to}))
 	} else {
 		// handle ctcp
-		if len(msg) > 2 && msg[0] == soh && msg[len(msg)-1] == soh {
-			irc.onCtcp(nick, msg)
+		if len(text) > 2 && text[0] == soh && text[len(text)-1] == soh {
+			irc.onCtcp(nick, text)
 			return
 		}
-		irc.Logger.Printf("<%s> %s", nick, msg)
+		irc.Logger.Printf("<%s> %s", nick, text)
 		irc.bot.AddEvent(NewEvent(PrivateMessage,
 			&PrivateMessageData{
-				EventBase{irc.bot, from, nick, user, host},
+				EventBase{irc.bot, msg.Source, nick, user, host},
 				irc,
-				msg}))
+				text}))
 	}
 }
 
-func (irc *IRC) onNotice(from, param string) {
+// format: :NickServ!NickServ@services. NOTICE candice :You are now identified
+func (irc *IRC) onNotice(msg *Message) {
 	var me string
-	var msg string
-	var arr []string
-	var nick string
+	var text string
+	var from string
 
-	nick, _, _ = matchNickUserHost(from)
-	if nick != "" {
-		from = nick
+	from = msg.Source
+	if msg.Nick != "" {
+		from = msg.Nick
+	}
+	me, text = msg.Param(0), msg.Param(1)
+	if !irc.IsMe(me) {
+		irc.Logger.Printf("Notice from %s to %s: %s", from, me, text)
+	} else {
+		irc.Logger.Printf("Notice from %s: %s", from, text)
+	}
+}
+
+// format: :fluter!~fluter@unaffiliated/fluter ACCOUNT fluter
+// account-notify, account is * when logged out
+func (irc *IRC) onAccount(msg *Message) {
+	var account string
+
+	account = msg.Param(0)
+	if account == "*" {
+		irc.setAccount(msg.Nick, "")
+		irc.Logger.Printf("%s logged out", msg.Nick)
+	} else {
+		irc.setAccount(msg.Nick, account)
+		irc.Logger.Printf("%s logged in as %s", msg.Nick, account)
 	}
-	arr = strings.SplitN(param, ":", 2)
-	me = strings.TrimSpace(arr[0])
-	msg = arr[1]
-	if me != irc.config.BotNick {
-		irc.Logger.Printf("Notice from %s to %s: %s", from, me, msg)
+}
+
+// format: :fluter!~fluter@unaffiliated/fluter AWAY :Gone
+// away-notify, no message when back
+func (irc *IRC) onAway(msg *Message) {
+	if len(msg.Params) == 0 {
+		irc.Logger.Printf("%s is back", msg.Nick)
 	} else {
-		irc.Logger.Printf("Notice from %s: %s", from, msg)
+		irc.Logger.Printf("%s is away: %s", msg.Nick, msg.Param(0))
 	}
 }
 
 // format:
 // user :candice MODE candice :+w
 // channel :ChanServ!ChanServ@services. MODE #freenode +q *!*@183.185.132.59
-func (irc *IRC) onMode(from, param string) {
+func (irc *IRC) onMode(msg *Message) {
 	var target string
-	var msg string
-	var arr []string
+	var mode string
 
-	arr = strings.SplitN(param, " ", 2)
-	target, msg = arr[0], arr[1]
+	target, mode = msg.Param(0), msg.Text(1)
 
-	if IsChannel(target) {
+	if irc.IsChannel(target) {
 		ch := irc.GetChannel(target)
-		if ch != nil {
-			ch.onMode(msg, from)
+		if ch != nil && len(msg.Params) > 1 {
+			ch.onMode(msg.Nick, msg.Param(1), msg.Params[2:])
 		}
 	} else {
-		if from != target {
-			panic(from + " != " + target)
+		if msg.Nick != target {
+			panic(msg.Source + " != " + target)
 		}
 		// TODO(fluter): check first char with + or -
-		irc.mode = msg
+		irc.mode = mode
 	}
 }
 
-func (irc *IRC) onError(from, param string) {
-	irc.Logger.Printf("Error %s", param)
+func (irc *IRC) onError(msg *Message) {
+	irc.Logger.Printf("Error %s", msg.Trailing())
 	irc.disconnect()
 }
 
 // handle numeric replies
 // F: 001 candice :Welcome to the freenode Internet Relay Chat Network candice
-func (irc *IRC) onRPL_WELCOME(from, param string) {
-	var arr []string
-
-	arr = strings.SplitN(param, ":", 2)
-	irc.Logger.Println(arr[1])
+func (irc *IRC) onRPL_WELCOME(msg *Message) {
+	irc.Logger.Println(msg.Trailing())
+
+	// registration is done, the server may not support CAP at all
+	irc.caps.finish()
+	irc.registered = true
+	if nick := msg.Param(0); nick != "" {
+		irc.setNick(nick)
+	}
+	if !irc.IsMe(irc.config.BotNick) {
+		irc.monitorNick()
+	}
+	if err := irc.joinChannels(); err != nil {
+		irc.Logger.Println("Failed to join pre-configured channels", err)
+	}
 }
 
 // F: 002 candice :Your host is rajaniemi.freenode.net[195.148.124.79/7000], running version ircd-seven-1.1.3
-func (irc *IRC) onRPL_YOURHOST(from, param string) {
+func (irc *IRC) onRPL_YOURHOST(msg *Message) {
 	var info string
 
-	info = irc.getReplyByColon(param)
+	info = msg.Trailing()
 	m := rpl_002Re.FindStringSubmatch(info)
-	irc.host, irc.version = m[1], m[3]
+	if m != nil {
+		irc.host, irc.version = m[1], m[3]
+	}
 
 	irc.Logger.Println(info)
 }
 
-func (irc *IRC) onRPL_CREATED(from, param string) {
-	var arr []string
-
-	arr = strings.SplitN(param, ":", 2)
-	irc.Logger.Println(arr[1])
+func (irc *IRC) onRPL_CREATED(msg *Message) {
+	irc.Logger.Println(msg.Trailing())
 }
 
-func (irc *IRC) onRPL_MYINFO(from, param string) {
-	var arr []string
-
-	arr = strings.SplitN(param, " ", 2)
-	irc.Logger.Println(arr[1])
+func (irc *IRC) onRPL_MYINFO(msg *Message) {
+	irc.Logger.Println(msg.Text(1))
 }
 
-func (irc *IRC) onRPL_ISUPPORT(from, param string) {
-	var arr []string
-
-	arr = strings.SplitN(param, " ", 2)
-	irc.Logger.Println(arr[1])
-}
-
-func (irc *IRC) onRPL_STATSCONN(from, param string) {
-	var arr []string
-
-	arr = strings.SplitN(param, ":", 2)
-	irc.Logger.Println(arr[1])
+func (irc *IRC) onRPL_STATSCONN(msg *Message) {
+	irc.Logger.Println(msg.Trailing())
 }
 
-func (irc *IRC) onRPL_LUSERCLIENT(from, param string) {
-	var arr []string
-
-	arr = strings.SplitN(param, ":", 2)
-	irc.Logger.Println(arr[1])
+func (irc *IRC) onRPL_LUSERCLIENT(msg *Message) {
+	irc.Logger.Println(msg.Trailing())
 }
 
-func (irc *IRC) onRPL_LUSEROP(from, param string) {
-	var arr []string
-
-	arr = strings.SplitN(param, " ", 2)
-	irc.Logger.Println(arr[1])
+func (irc *IRC) onRPL_LUSEROP(msg *Message) {
+	irc.Logger.Println(msg.Text(1))
 }
 
-func (irc *IRC) onRPL_LUSERUNKNOWN(from, param string) {
-	var arr []string
-
-	arr = strings.SplitN(param, " ", 2)
-	irc.Logger.Println(arr[1])
+func (irc *IRC) onRPL_LUSERUNKNOWN(msg *Message) {
+	irc.Logger.Println(msg.Text(1))
 }
 
-func (irc *IRC) onRPL_LUSERCHANNELS(from, param string) {
-	var arr []string
-
-	arr = strings.SplitN(param, " ", 2)
-	irc.Logger.Println(arr[1])
+func (irc *IRC) onRPL_LUSERCHANNELS(msg *Message) {
+	irc.Logger.Println(msg.Text(1))
 }
 
-func (irc *IRC) onRPL_LUSERME(from, param string) {
-	var arr []string
-
-	arr = strings.SplitN(param, ":", 2)
-	irc.Logger.Println(arr[1])
+func (irc *IRC) onRPL_LUSERME(msg *Message) {
+	irc.Logger.Println(msg.Trailing())
 }
 
-func (irc *IRC) onRPL_LOCALUSERS(from, param string) {
-	var arr []string
-
-	arr = strings.SplitN(param, " ", 2)
-	irc.Logger.Println(arr[1])
+func (irc *IRC) onRPL_LOCALUSERS(msg *Message) {
+	irc.Logger.Println(msg.Text(1))
 }
 
-func (irc *IRC) onRPL_GLOBALUSERS(from, param string) {
-	var arr []string
-
-	arr = strings.SplitN(param, " ", 2)
-	irc.Logger.Println(arr[1])
+func (irc *IRC) onRPL_GLOBALUSERS(msg *Message) {
+	irc.Logger.Println(msg.Text(1))
 }
 
 // format: 311 candice candice ~gbot unaffiliated/fluter/bot/candice * :Dr Hu Shih
-func (irc *IRC) onRPL_WHOISUSER(from, param string) {
+func (irc *IRC) onRPL_WHOISUSER(msg *Message) {
 	var nick, user, host, name string
-	var userstr string
-	var arr []string
 
-	userstr = irc.getReplyBySpace(param)
-	arr = strings.SplitN(userstr, " ", 5)
-	nick, user, host, name = arr[0], arr[1], arr[2], arr[4][1:]
+	nick, user, host, name = msg.Param(1), msg.Param(2), msg.Param(3), msg.Param(5)
 
 	irc.Logger.Printf("[%s] (%s@%s): %s", nick, user, host, name)
 }
 
 // format: 312 candice candice rajaniemi.freenode.net :Helsinki, FI, EU
-func (irc *IRC) onRPL_WHOISSERVER(from, param string) {
+func (irc *IRC) onRPL_WHOISSERVER(msg *Message) {
 	var nick, svr, geo string
-	var server string
-	var arr []string
 
-	server = irc.getReplyBySpace(param)
-	arr = strings.SplitN(server, " ", 3)
-	nick, svr, geo = arr[0], arr[1], arr[2][1:]
+	nick, svr, geo = msg.Param(1), msg.Param(2), msg.Param(3)
 	irc.Logger.Printf("[%s] %s (%s)", nick, svr, geo)
 }
 
 // format:
-func (irc *IRC) onRPL_WHOISOPERATOR(from, param string) {
-	userstr := irc.getReplyBySpace(param)
-	irc.Logger.Printf("OP: %s", userstr)
+func (irc *IRC) onRPL_WHOISOPERATOR(msg *Message) {
+	irc.Logger.Printf("OP: %s", msg.Text(1))
 }
 
-func (irc *IRC) onRPL_WHOWASUSER(from, param string) {
-	userstr := irc.getReplyBySpace(param)
-	irc.Logger.Printf("WAS: %s", userstr)
+func (irc *IRC) onRPL_WHOWASUSER(msg *Message) {
+	irc.Logger.Printf("WAS: %s", msg.Text(1))
 }
 
-func (irc *IRC) onRPL_ENDOFWHO(from, param string) {
-	userstr := irc.getReplyBySpace(param)
-	irc.Logger.Printf("ENDWHO: %s", userstr)
+func (irc *IRC) onRPL_ENDOFWHO(msg *Message) {
+	irc.Logger.Printf("ENDWHO: %s", msg.Text(1))
 }
 
 // format: 317 candice candice 30 1452309570 :seconds idle, signon time
-func (irc *IRC) onRPL_WHOISIDLE(from, param string) {
+func (irc *IRC) onRPL_WHOISIDLE(msg *Message) {
 	var nick, idles, signons string
 	var idle int
-	var arr []string
 
-	idlestr := irc.getReplyBySpace(param)
-	arr = strings.SplitN(idlestr, " ", 4)
-	nick, idles, signons = arr[0], arr[1], arr[2]
+	nick, idles, signons = msg.Param(1), msg.Param(2), msg.Param(3)
 
 	var day, hour, min, sec int
 
@@ -450,42 +456,32 @@ func (irc *IRC) onRPL_WHOISIDLE(from, param string) {
 }
 
 // format: 318 candice candice :End of /WHOIS list.
-func (irc *IRC) onRPL_ENDOFWHOIS(from, param string) {
+func (irc *IRC) onRPL_ENDOFWHOIS(msg *Message) {
 	var nick, info string
-	var arr []string
-	endstr := irc.getReplyBySpace(param)
-	arr = strings.SplitN(endstr, " ", 2)
-	nick, info = arr[0], arr[1][1:]
+
+	nick, info = msg.Param(1), msg.Param(2)
 	irc.Logger.Printf("[%s] %s", nick, info)
 }
 
 // format: 319 candice candice :#rdma #candice
-func (irc *IRC) onRPL_WHOISCHANNELS(from, param string) {
+func (irc *IRC) onRPL_WHOISCHANNELS(msg *Message) {
 	var nick string
 	var chanlist string
-	var chanstr string
-	var arr []string
 
-	chanstr = irc.getReplyBySpace(param)
-	arr = strings.SplitN(chanstr, " ", 2)
-	nick, chanlist = arr[0], arr[1][1:]
+	nick, chanlist = msg.Param(1), msg.Param(2)
 
 	irc.Logger.Printf("[%s] %s", nick, chanlist)
 }
 
-func (irc *IRC) onRPL_WHOISSPECIAL(from, param string) {
-	idlestr := irc.getReplyBySpace(param)
-	irc.Logger.Printf("CHANNELS: %s", idlestr)
+func (irc *IRC) onRPL_WHOISSPECIAL(msg *Message) {
+	irc.Logger.Printf("CHANNELS: %s", msg.Text(1))
 }
 
 // format: 328 candice #freenode :http://freenode.net/
-func (irc *IRC) onRPL_CHANNELURL(from, param string) {
+func (irc *IRC) onRPL_CHANNELURL(msg *Message) {
 	var chn, url string
-	var arr []string
 
-	urlstr := irc.getReplyBySpace(param)
-	arr = strings.SplitN(urlstr, " ", 2)
-	chn, url = arr[0], arr[1][1:]
+	chn, url = msg.Param(1), msg.Param(2)
 
 	//	irc.Logger.Printf("URL for %s: %s", chn, url)
 
@@ -498,31 +494,29 @@ func (irc *IRC) onRPL_CHANNELURL(from, param string) {
 }
 
 // format:
-func (irc *IRC) onRPL_CREATIONTIME(from, param string) {
+func (irc *IRC) onRPL_CREATIONTIME(msg *Message) {
 }
 
 // format: 330 candice fluter fluter :is logged in as
-func (irc *IRC) onRPL_WHOISLOGGEDIN(from, param string) {
+func (irc *IRC) onRPL_WHOISLOGGEDIN(msg *Message) {
 	var nick, as, info string
-	var arr []string
 
-	logstr := irc.getReplyBySpace(param)
-	arr = strings.SplitN(logstr, " ", 3)
-	nick, as, info = arr[0], arr[1], arr[2][1:]
+	nick, as, info = msg.Param(1), msg.Param(2), msg.Param(3)
+	// changes of the account are only seen in the shared channels
+	if irc.sharesChannel(nick) {
+		irc.setAccount(nick, as)
+	}
 	irc.Logger.Printf("[%s] %s %s", nick, info, as)
 }
 
-func (irc *IRC) onRPL_NOTOPIC(from, param string) {
+func (irc *IRC) onRPL_NOTOPIC(msg *Message) {
 }
 
 // format: 332 candice #hpc :meh - All things High Performance Computing (HPC) / Parallel Programming / Decoding 42
-func (irc *IRC) onRPL_TOPIC(from, param string) {
+func (irc *IRC) onRPL_TOPIC(msg *Message) {
 	var chn, topic string
-	var arr []string
 
-	topicstr := irc.getReplyBySpace(param)
-	arr = strings.SplitN(topicstr, " ", 2)
-	chn, topic = arr[0], arr[1][1:]
+	chn, topic = msg.Param(1), msg.Param(2)
 
 	var ch *Channel
 	ch = irc.GetChannel(chn)
@@ -532,21 +526,18 @@ func (irc *IRC) onRPL_TOPIC(from, param string) {
 }
 
 // format: 333 candice #hpc EOF!~hamiltonh@dsl-173-206-247-218.tor.primus.ca 1290112601
-func (irc *IRC) onRPL_TOPICWHOTIME(from, param string) {
+func (irc *IRC) onRPL_TOPICWHOTIME(msg *Message) {
 	var chn, who, time string
-	var arr []string
 
-	topicstr := irc.getReplyBySpace(param)
-	arr = strings.SplitN(topicstr, " ", 3)
-	chn, who, time = arr[0], arr[1], arr[2]
+	chn, who, time = msg.Param(1), msg.Param(2), msg.Param(3)
 
 	var nick, user, host string
 	var timestr string
 
-	nick, user, host = matchNickUserHost(who)
+	nick, user, host = parseSource(who)
 	timestr = unixTimeStr(time)
 
-	if nick != "" {
+	if user != "" {
 		who = fmt.Sprintf("%s (%s@%s)", nick, user, host)
 	}
 	//	irc.Logger.Printf("Topic for %s set by %s on %s", chn,
@@ -559,139 +550,149 @@ func (irc *IRC) onRPL_TOPICWHOTIME(from, param string) {
 	}
 }
 
-func (irc *IRC) onRPL_NAMREPLY(from, param string) {
+// format: 353 candice = #candice :candice @fluter +foo
+func (irc *IRC) onRPL_NAMREPLY(msg *Message) {
 	var chn string
-	var me string
 	var mode byte
-	var arr []string
 	var nicks string
 
-	arr = strings.SplitN(param, ":", 2)
-	if len(arr) != 2 {
-		panic(arr)
-	}
-	nicks = arr[1]
-	arr = strings.Split(strings.TrimSpace(arr[0]), " ")
-	if len(arr) != 3 {
-		panic(arr)
-	}
-	me, mode, chn = arr[0], arr[1][0], arr[2]
-
-	if me != irc.config.BotNick {
-		panic(me)
+	if len(msg.Params) < 4 {
+		irc.Logger.Printf("Invalid NAMES reply: %s", msg)
+		return
 	}
+	mode, chn, nicks = msg.Param(1)[0], msg.Param(2), msg.Param(3)
 
 	var ch *Channel
 
 	ch = irc.GetChannel(chn)
-	ch.mode = mode
+	if ch == nil {
+		irc.Logger.Printf("NAMES reply for unknown channel %s", chn)
+		return
+	}
+	ch.visibility = mode
 	ch.onRPL_NAMREPLY(nicks)
 }
 
 // format: 366 fluter #botters-test :End of /NAMES list.
-func (irc *IRC) onRPL_ENDOFNAMES(from, param string) {
+func (irc *IRC) onRPL_ENDOFNAMES(msg *Message) {
 	var chn string
-	var me string
-	var arr []string
 
-	arr = strings.SplitN(param, ":", 2)
-	if len(arr) != 2 {
-		panic(arr)
+	chn = msg.Param(1)
+
+	var ch *Channel
+
+	ch = irc.GetChannel(chn)
+	if ch == nil {
+		irc.Logger.Printf("End of NAMES for unknown channel %s", chn)
+		return
 	}
-	// arr[1] = :End of /NAMES list.
-	arr = strings.Split(strings.TrimSpace(arr[0]), " ")
-	if len(arr) != 2 {
-		panic(arr)
+	ch.onRPL_ENDOFNAMES()
+}
+
+// format: 324 candice #botters-test +ntk key
+func (irc *IRC) onRPL_CHANNELMODEIS(msg *Message) {
+	var chn string
+
+	if len(msg.Params) < 3 {
+		irc.Logger.Printf("Invalid CHANNELMODEIS reply: %s", msg)
+		return
 	}
-	me, chn = arr[0], arr[1]
+	chn = msg.Param(1)
 
-	if me != irc.config.BotNick {
-		panic(me)
+	var ch *Channel
+
+	ch = irc.GetChannel(chn)
+	if ch == nil {
+		irc.Logger.Printf("Modes for unknown channel %s", chn)
+		return
 	}
-	if irc.GetChannel(chn) == nil {
-		panic(chn)
+	ch.onRPL_CHANNELMODEIS(msg.Param(2), msg.Params[3:])
+}
+
+// format: 367 candice #botters-test *!*@spam.example fluter 1469948036
+func (irc *IRC) onRPL_BANLIST(msg *Message) {
+	irc.onListEntry(msg, 'b')
+}
+
+// format: 348 candice #botters-test *!*@friend.example fluter 1469948036
+func (irc *IRC) onRPL_EXCEPTLIST(msg *Message) {
+	irc.onListEntry(msg, 'e')
+}
+
+// format: 346 candice #botters-test *!*@friend.example fluter 1469948036
+func (irc *IRC) onRPL_INVEXLIST(msg *Message) {
+	irc.onListEntry(msg, 'I')
+}
+
+func (irc *IRC) onListEntry(msg *Message, mode byte) {
+	var chn, mask string
+
+	if len(msg.Params) < 3 {
+		irc.Logger.Printf("Invalid list reply: %s", msg)
+		return
 	}
+	chn, mask = msg.Param(1), msg.Param(2)
 
 	var ch *Channel
 
 	ch = irc.GetChannel(chn)
-	ch.onRPL_ENDOFNAMES()
+	if ch == nil {
+		irc.Logger.Printf("List reply for unknown channel %s", chn)
+		return
+	}
+	ch.onListEntry(mode, mask)
 }
 
-func (irc *IRC) onRPL_MOTD(from, param string) {
-	var motd string
-	var arr []string
+// format: 368 candice #botters-test :End of Channel Ban List
+func (irc *IRC) onRPL_ENDOFBANLIST(msg *Message) {
+	var chn string
+
+	chn = msg.Param(1)
 
-	arr = strings.SplitN(param, ":", 2)
-	motd = arr[1]
-	irc.Logger.Printf("MOTD: %s", motd)
+	var ch *Channel
+
+	ch = irc.GetChannel(chn)
+	if ch == nil {
+		return
+	}
+	irc.Logger.Printf("%d %s on %s", len(ch.Bans()), sp("ban", "bans", len(ch.Bans())), chn)
 }
 
-func (irc *IRC) onRPL_MOTDSTART(from, param string) {
-	var start string
-	var arr []string
+func (irc *IRC) onRPL_MOTD(msg *Message) {
+	irc.Logger.Printf("MOTD: %s", msg.Trailing())
+}
 
-	arr = strings.SplitN(param, ":", 2)
-	start = arr[1]
-	irc.Logger.Printf("      %s", start)
+func (irc *IRC) onRPL_MOTDSTART(msg *Message) {
+	irc.Logger.Printf("      %s", msg.Trailing())
 }
 
-func (irc *IRC) onRPL_ENDOFMOTD(from, param string) {
+func (irc *IRC) onRPL_ENDOFMOTD(msg *Message) {
 	// end of motd
 }
 
 // format: 378 candice candice :is connecting from *@69.4.235.219 69.4.235.219
-func (irc *IRC) onRPL_WHOISHOST(from, param string) {
+func (irc *IRC) onRPL_WHOISHOST(msg *Message) {
 	var nick, info string
-	var arr []string
 
-	hoststr := irc.getReplyBySpace(param)
-	arr = strings.SplitN(hoststr, " ", 2)
-	nick, info = arr[0], arr[1][1:]
+	nick, info = msg.Param(1), msg.Param(2)
 
 	irc.Logger.Printf("[%s] %s", nick, info)
 }
 
-func (irc *IRC) onRPL_HOSTHIDDEN(from, param string) {
-	var me string
-	var mask string
-	var arr []string
-
-	arr = strings.SplitN(param, ":", 2)
-	if len(arr) != 2 {
-		panic(arr)
+// format: 396 candice unaffiliated/fluter/bot/candice :is now your hidden host (set by services.)
+func (irc *IRC) onRPL_HOSTHIDDEN(msg *Message) {
+	if msg.Param(1) == "" {
+		irc.Logger.Printf("Invalid hidden host reply: %s", msg)
+		return
 	}
-
-	// arr[1]=:is now your hidden host (set by services.)
-	arr = strings.Split(strings.TrimSpace(arr[0]), " ")
-	if len(arr) != 2 {
-		panic(arr)
-	}
-	me = arr[0]
-	if me != irc.config.BotNick {
-		panic(me)
-	}
-	mask = arr[1]
-	irc.cloak = mask
-}
-
-func (irc *IRC) onERR_INVITEONLYCHAN(from, param string) {
-	var errmsg string
-	var arr []string
-
-	arr = strings.SplitN(param, " ", 2)
-	errmsg = arr[1]
-	irc.Logger.Printf("%s", errmsg)
+	irc.cloak = msg.Param(1)
 }
 
 // format: 671 candice fluter :is using a secure connection
-func (irc *IRC) onRPL_WHOISSECURE(from, param string) {
+func (irc *IRC) onRPL_WHOISSECURE(msg *Message) {
 	var nick, info string
-	var arr []string
 
-	secstr := irc.getReplyBySpace(param)
-	arr = strings.SplitN(secstr, " ", 2)
-	nick, info = arr[0], arr[1][1:]
+	nick, info = msg.Param(1), msg.Param(2)
 
 	irc.Logger.Printf("[%s] %s", nick, info)
 }
@@ -0,0 +1,272 @@
+// Copyright 2016 Alex Fluter
+
+package bot
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// defaults used before the server sends RPL_ISUPPORT, as in RFC 1459
+const (
+	DefaultChanTypes   = "#&"
+	DefaultPrefix      = "(ov)@+"
+	DefaultChanModes   = "beI,k,l,imnpst"
+	DefaultCaseMapping = "rfc1459"
+	DefaultNickLen     = 9
+	DefaultModes       = 3
+	DefaultLineLen     = 512
+)
+
+// ISupport is the set of features advertised by the server in RPL_ISUPPORT.
+type ISupport struct {
+	sync.RWMutex
+	tokens map[string]string
+}
+
+func NewISupport() *ISupport {
+	s := new(ISupport)
+	s.tokens = make(map[string]string)
+	return s
+}
+
+// parse updates the features from the tokens of a RPL_ISUPPORT reply,
+// in the form of KEY, KEY=VALUE or -KEY.
+func (s *ISupport) parse(tokens []string) {
+	s.Lock()
+	defer s.Unlock()
+	for _, token := range tokens {
+		if token == "" {
+			continue
+		}
+		if token[0] == '-' {
+			delete(s.tokens, strings.ToUpper(token[1:]))
+			continue
+		}
+		arr := strings.SplitN(token, "=", 2)
+		key := strings.ToUpper(arr[0])
+		if len(arr) == 2 {
+			s.tokens[key] = unescapeISupport(arr[1])
+		} else {
+			s.tokens[key] = ""
+		}
+	}
+}
+
+// reset forgets the features of the previous connection, the ISupport
+// is shared by the goroutines of the network so it is never replaced.
+func (s *ISupport) reset() {
+	s.Lock()
+	s.tokens = make(map[string]string)
+	s.Unlock()
+}
+
+// unescapeISupport decodes the \xHH sequences in values.
+func unescapeISupport(value string) string {
+	var buf []byte
+
+	if strings.Index(value, "\\x") == -1 {
+		return value
+	}
+	buf = make([]byte, 0, len(value))
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+3 < len(value) && value[i+1] == 'x' {
+			if c, err := strconv.ParseUint(value[i+2:i+4], 16, 8); err == nil {
+				buf = append(buf, byte(c))
+				i += 3
+				continue
+			}
+		}
+		buf = append(buf, value[i])
+	}
+	return string(buf)
+}
+
+// Get returns the value of the token and whether it is advertised.
+func (s *ISupport) Get(key string) (string, bool) {
+	s.RLock()
+	defer s.RUnlock()
+	value, ok := s.tokens[strings.ToUpper(key)]
+	return value, ok
+}
+
+func (s *ISupport) getDefault(key, def string) string {
+	value, ok := s.Get(key)
+	if !ok || value == "" {
+		return def
+	}
+	return value
+}
+
+func (s *ISupport) getInt(key string, def int) int {
+	value, ok := s.Get(key)
+	if !ok {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+func (s *ISupport) String() string {
+	var tokens []string
+
+	s.RLock()
+	for k, v := range s.tokens {
+		if v == "" {
+			tokens = append(tokens, k)
+		} else {
+			tokens = append(tokens, k+"="+v)
+		}
+	}
+	s.RUnlock()
+	sort.Strings(tokens)
+	return strings.Join(tokens, " ")
+}
+
+// ChanTypes returns the prefixes of the channel names.
+func (s *ISupport) ChanTypes() string {
+	value, ok := s.Get("CHANTYPES")
+	if !ok {
+		return DefaultChanTypes
+	}
+	return value
+}
+
+// Prefix returns the channel membership modes and their nick prefixes,
+// in the order of rank, e.g. "ov" and "@+".
+func (s *ISupport) Prefix() (modes string, prefixes string) {
+	value, ok := s.Get("PREFIX")
+	if !ok {
+		value = DefaultPrefix
+	}
+	return parsePrefix(value)
+}
+
+func parsePrefix(value string) (string, string) {
+	i := strings.IndexByte(value, ')')
+	if i == -1 || value[0] != '(' || len(value[1:i]) != len(value[i+1:]) {
+		return "", ""
+	}
+	return value[1:i], value[i+1:]
+}
+
+// ChanModes returns the channel modes in the 4 groups: list modes,
+// modes with parameter, modes with parameter when set, and flags.
+func (s *ISupport) ChanModes() [4]string {
+	var groups [4]string
+
+	arr := strings.Split(s.getDefault("CHANMODES", DefaultChanModes), ",")
+	for i := 0; i < len(groups) && i < len(arr); i++ {
+		groups[i] = arr[i]
+	}
+	return groups
+}
+
+func (s *ISupport) CaseMapping() string {
+	return strings.ToLower(s.getDefault("CASEMAPPING", DefaultCaseMapping))
+}
+
+func (s *ISupport) NickLen() int {
+	return s.getInt("NICKLEN", DefaultNickLen)
+}
+
+// TopicLen returns the max length of topic, 0 if not limited.
+func (s *ISupport) TopicLen() int {
+	return s.getInt("TOPICLEN", 0)
+}
+
+// Modes returns the max number of modes with parameter in one MODE command.
+func (s *ISupport) Modes() int {
+	return s.getInt("MODES", DefaultModes)
+}
+
+func (s *ISupport) LineLen() int {
+	return s.getInt("LINELEN", DefaultLineLen)
+}
+
+func (s *ISupport) Network() string {
+	value, _ := s.Get("NETWORK")
+	return value
+}
+
+// StatusMsg returns the prefixes to send messages to channel members
+// with the status, e.g. PRIVMSG @#channel.
+func (s *ISupport) StatusMsg() string {
+	value, _ := s.Get("STATUSMSG")
+	return value
+}
+
+// TargMax returns the max number of targets for cmd, 0 if not limited.
+func (s *ISupport) TargMax(cmd string) int {
+	value, ok := s.Get("TARGMAX")
+	if !ok {
+		return 0
+	}
+	cmd = strings.ToUpper(cmd)
+	for _, t := range strings.Split(value, ",") {
+		arr := strings.SplitN(t, ":", 2)
+		if strings.ToUpper(arr[0]) != cmd {
+			continue
+		}
+		if len(arr) == 2 {
+			if n, err := strconv.Atoi(arr[1]); err == nil {
+				return n
+			}
+		}
+		return 0
+	}
+	return 0
+}
+
+// IsChannel returns true if name starts with one of the channel types.
+func (s *ISupport) IsChannel(name string) bool {
+	if name == "" {
+		return false
+	}
+	return strings.IndexByte(s.ChanTypes(), name[0]) != -1
+}
+
+// TrimStatusMsg removes the status prefix from a message target.
+func (s *ISupport) TrimStatusMsg(target string) string {
+	statusmsg := s.StatusMsg()
+	for len(target) > 1 && strings.IndexByte(statusmsg, target[0]) != -1 {
+		target = target[1:]
+	}
+	return target
+}
+
+// TrimPrefix removes the membership prefixes from a nick in NAMES reply,
+// returns the prefixes and the nick.
+func (s *ISupport) TrimPrefix(nick string) (string, string) {
+	_, prefixes := s.Prefix()
+	i := 0
+	for i < len(nick) && strings.IndexByte(prefixes, nick[i]) != -1 {
+		i++
+	}
+	return nick[:i], nick[i:]
+}
+
+// ISupport returns the features advertised by the server.
+func (irc *IRC) ISupport() *ISupport {
+	return irc.isupport
+}
+
+// IsChannel returns true if name is a channel on this server.
+func (irc *IRC) IsChannel(name string) bool {
+	return irc.isupport.IsChannel(name)
+}
+
+// format: 005 candice CHANTYPES=# EXCEPTS INVEX CHANMODES=eIbq,k,flj,CFLMPQScgimnprstz :are supported by this server
+func (irc *IRC) onRPL_ISUPPORT(msg *Message) {
+	if len(msg.Params) < 3 {
+		irc.Logger.Printf("Invalid ISUPPORT reply: %s", msg)
+		return
+	}
+	irc.isupport.parse(msg.Params[1 : len(msg.Params)-1])
+	irc.Logger.Println(msg.Text(1))
+}
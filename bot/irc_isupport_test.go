@@ -0,0 +1,59 @@
+package bot
+
+import (
+	"testing"
+)
+
+func TestISupport(t *testing.T) {
+	s := NewISupport()
+
+	if !s.IsChannel("#candice") || !s.IsChannel("&candice") || s.IsChannel("+candice") {
+		t.Error("default CHANTYPES")
+	}
+
+	s.parse([]string{"CHANTYPES=#+", "PREFIX=(qaohv)~&@%+",
+		"CHANMODES=eIbq,k,flj,CFLMPQScgimnprstz", "CASEMAPPING=ascii",
+		"NICKLEN=16", "TOPICLEN=390", "NETWORK=Libera\\x20Chat",
+		"STATUSMSG=@+", "TARGMAX=NAMES:1,PRIVMSG:4,JOIN:", "EXCEPTS"})
+
+	if !s.IsChannel("+candice") || s.IsChannel("&candice") || s.IsChannel("") {
+		t.Error(s.ChanTypes())
+	}
+	if modes, prefixes := s.Prefix(); modes != "qaohv" || prefixes != "~&@%+" {
+		t.Error(modes, prefixes)
+	}
+	if groups := s.ChanModes(); groups[0] != "eIbq" || groups[3] != "CFLMPQScgimnprstz" {
+		t.Error(groups)
+	}
+	if s.CaseMapping() != "ascii" || s.NickLen() != 16 || s.TopicLen() != 390 {
+		t.Error(s)
+	}
+	if s.Network() != "Libera Chat" {
+		t.Error(s.Network())
+	}
+	if s.TargMax("privmsg") != 4 || s.TargMax("JOIN") != 0 || s.TargMax("KICK") != 0 {
+		t.Error(s.Get("TARGMAX"))
+	}
+	if _, ok := s.Get("EXCEPTS"); !ok {
+		t.Error("EXCEPTS")
+	}
+	if target := s.TrimStatusMsg("@#candice"); target != "#candice" {
+		t.Error(target)
+	}
+	if prefixes, nick := s.TrimPrefix("~@fluter"); prefixes != "~@" || nick != "fluter" {
+		t.Error(prefixes, nick)
+	}
+
+	s.parse([]string{"-EXCEPTS", "-NICKLEN"})
+	if _, ok := s.Get("EXCEPTS"); ok {
+		t.Error("EXCEPTS")
+	}
+	if s.NickLen() != DefaultNickLen {
+		t.Error(s.NickLen())
+	}
+
+	s.reset()
+	if s.CaseMapping() != DefaultCaseMapping || s.IsChannel("+candice") {
+		t.Error(s)
+	}
+}
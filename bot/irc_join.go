@@ -0,0 +1,223 @@
+// Copyright 2016 Alex Fluter
+
+package bot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// joinRetryBase is the wait before the first retry of a failed join.
+const joinRetryBase = 30 * time.Second
+
+type JoinState int
+
+const (
+	Joining JoinState = iota
+	Joined
+	JoinFailed
+)
+
+func (s JoinState) String() string {
+	switch s {
+	case Joining:
+		return "joining"
+	case Joined:
+		return "joined"
+	case JoinFailed:
+		return "failed"
+	}
+	return fmt.Sprintf("%d", s)
+}
+
+// channelJoin tracks the join of a channel until it succeeds.
+type channelJoin struct {
+	name   string
+	key    string
+	state  JoinState
+	reason string
+	tries  int
+	timer  *time.Timer
+}
+
+func (j *channelJoin) String() string {
+	if j.state == JoinFailed {
+		return fmt.Sprintf("%s %s (%s)", j.name, j.state, j.reason)
+	}
+	return fmt.Sprintf("%s %s", j.name, j.state)
+}
+
+func (j *channelJoin) stopTimer() {
+	if j.timer != nil {
+		j.timer.Stop()
+		j.timer = nil
+	}
+}
+
+// getJoin returns the join of the channel, caller must hold joinLock.
+func (irc *IRC) getJoin(chn string) *channelJoin {
+	var name string
+
+	name = irc.isupport.Fold(chn)
+	j, ok := irc.joins[name]
+	if !ok {
+		j = &channelJoin{name: chn}
+		irc.joins[name] = j
+	}
+	return j
+}
+
+// joinChannel sends the JOIN and tracks it until the server confirms.
+func (irc *IRC) joinChannel(chn, key string) error {
+	irc.joinLock.Lock()
+	j := irc.getJoin(chn)
+	if key != "" {
+		j.key = key
+	}
+	key = j.key
+	j.state = Joining
+	j.reason = ""
+	j.stopTimer()
+	irc.joinLock.Unlock()
+
+	return irc.JoinKey(chn, key)
+}
+
+func (irc *IRC) setJoined(chn string) {
+	irc.joinLock.Lock()
+	defer irc.joinLock.Unlock()
+	j := irc.getJoin(chn)
+	j.state = Joined
+	j.reason = ""
+	j.tries = 0
+	j.stopTimer()
+}
+
+func (irc *IRC) setJoinFailed(chn, reason string) {
+	irc.joinLock.Lock()
+	defer irc.joinLock.Unlock()
+	j := irc.getJoin(chn)
+	j.state = JoinFailed
+	j.reason = reason
+}
+
+// forgetJoin stops tracking the channel, e.g. after leaving it.
+func (irc *IRC) forgetJoin(chn string) {
+	var name string
+
+	irc.joinLock.Lock()
+	defer irc.joinLock.Unlock()
+	name = irc.isupport.Fold(chn)
+	if j, ok := irc.joins[name]; ok {
+		j.stopTimer()
+		delete(irc.joins, name)
+	}
+}
+
+func (irc *IRC) resetJoins() {
+	irc.joinLock.Lock()
+	defer irc.joinLock.Unlock()
+	for name, j := range irc.joins {
+		j.stopTimer()
+		delete(irc.joins, name)
+	}
+}
+
+// scheduleJoin joins the channel again after delay.
+func (irc *IRC) scheduleJoin(chn, key string, delay time.Duration) {
+	irc.joinLock.Lock()
+	defer irc.joinLock.Unlock()
+	j := irc.getJoin(chn)
+	if key != "" {
+		j.key = key
+	}
+	j.stopTimer()
+	j.timer = time.AfterFunc(delay, func() {
+		irc.retryJoin(chn)
+	})
+}
+
+func (irc *IRC) retryJoin(chn string) {
+	var key string
+
+	if irc.stopping || irc.GetChannel(chn) != nil {
+		return
+	}
+	irc.joinLock.Lock()
+	j, ok := irc.joins[irc.isupport.Fold(chn)]
+	if ok {
+		key = j.key
+	}
+	irc.joinLock.Unlock()
+	if !ok {
+		return
+	}
+	irc.joinChannel(chn, key)
+}
+
+// JoinState returns the join state of the channel and the reason of failure.
+func (irc *IRC) JoinState(chn string) (JoinState, string, bool) {
+	irc.joinLock.Lock()
+	defer irc.joinLock.Unlock()
+	j, ok := irc.joins[irc.isupport.Fold(chn)]
+	if !ok {
+		return Joining, "", false
+	}
+	return j.state, j.reason, true
+}
+
+func (irc *IRC) joinStatus() string {
+	var joins []string
+
+	irc.joinLock.Lock()
+	for _, j := range irc.joins {
+		joins = append(joins, j.String())
+	}
+	irc.joinLock.Unlock()
+	sort.Strings(joins)
+	return strings.Join(joins, ", ")
+}
+
+// format: 473 candice #botters-test :Cannot join channel (+i) - you must be invited
+func (irc *IRC) onJoinError(msg *Message) {
+	var chn, reason, key string
+	var tries int
+	var delay time.Duration
+
+	chn, reason = msg.Param(1), msg.Param(2)
+	if chn == "" {
+		irc.Logger.Printf("Invalid join error: %s", msg)
+		return
+	}
+	irc.Logger.Printf("Failed to join %s: %s", chn, reason)
+	irc.setJoinFailed(chn, reason)
+	if msg.Command == "ERR_TOOMANYCHANNELS" {
+		// retrying won't help until other channels are left
+		return
+	}
+
+	irc.joinLock.Lock()
+	j := irc.getJoin(chn)
+	j.tries++
+	tries, key = j.tries, j.key
+	irc.joinLock.Unlock()
+	if tries > irc.config.GetJoinRetryMax() {
+		irc.Logger.Printf("Giving up joining %s after %d %s",
+			chn, tries-1, sp("retry", "retries", tries-1))
+		return
+	}
+
+	if irc.config.JoinChanServ {
+		switch msg.Command {
+		case "ERR_INVITEONLYCHAN":
+			irc.Privmsg("ChanServ", "INVITE "+chn)
+		case "ERR_BANNEDFROMCHAN":
+			irc.Privmsg("ChanServ", "UNBAN "+chn)
+		}
+	}
+	delay = backoff(tries, joinRetryBase, irc.config.GetReconnectMax())
+	irc.Logger.Printf("Retrying to join %s in %s", chn, delay)
+	irc.scheduleJoin(chn, key, delay)
+}
@@ -0,0 +1,49 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestJoinFailure(t *testing.T) {
+	ch := make(chan bool)
+	bot := newTestBot(ch)
+
+	irc := testIRC(bot, t)
+	r := testConn(irc)
+	irc.config.JoinChanServ = true
+
+	go irc.joinChannel("#candice", "")
+	if s := readLine(r); s != "JOIN #candice\r\n" {
+		t.Error(s)
+	}
+	if state, _, ok := irc.JoinState("#candice"); !ok || state != Joining {
+		t.Error(state)
+	}
+
+	go irc.onCommand(command("ERR_INVITEONLYCHAN", "server", G+" #candice :Cannot join channel (+i) - you must be invited"))
+	if s := readLine(r); s != "PRIVMSG ChanServ :INVITE #candice\r\n" {
+		t.Error(s)
+	}
+	state, reason, _ := irc.JoinState("#candice")
+	if state != JoinFailed || !strings.Contains(reason, "(+i)") {
+		t.Error(state, reason)
+	}
+	if s := irc.joinStatus(); s != "#candice failed (Cannot join channel (+i) - you must be invited)" {
+		t.Error(s)
+	}
+	irc.conn = nil
+
+	irc.onCommand(command("JOIN", G+"!~u@host", "#candice"))
+	if state, _, _ := irc.JoinState("#candice"); state != Joined {
+		t.Error(state)
+	}
+	irc.onCommand(command("ERR_TOOMANYCHANNELS", "server", G+" #foo :You have joined too many channels"))
+	irc.joinLock.Lock()
+	if j := irc.joins["#foo"]; j == nil || j.timer != nil || j.state != JoinFailed {
+		t.Error(j)
+	}
+	irc.joinLock.Unlock()
+
+	delTestBot(bot, t, ch)
+}
@@ -0,0 +1,91 @@
+// Copyright 2016 Alex Fluter
+
+package bot
+
+import (
+	"time"
+)
+
+// rejoinWindow is the time after the last kick to reset the rejoin attempts.
+const rejoinWindow = 10 * time.Minute
+
+type rejoinState struct {
+	tries int
+	last  time.Time
+}
+
+// format: :fluter!~fluter@unaffiliated/fluter KICK #candice foo :flooding
+func (irc *IRC) onKick(msg *Message) {
+	var nick, user, host string
+	var chn, victim, reason string
+	var key string
+	var ch *Channel
+
+	nick, user, host = msg.Nick, msg.User, msg.Host
+	chn, victim, reason = msg.Param(0), msg.Param(1), msg.Param(2)
+	if chn == "" || victim == "" {
+		irc.Logger.Printf("Invalid KICK message: %s", msg)
+		return
+	}
+
+	ch = irc.GetChannel(chn)
+	if ch != nil {
+		ch.onKick(nick, victim, reason)
+	}
+
+	if irc.IsMe(victim) {
+		irc.Logger.Printf("Kicked from %s by %s (%s)", chn, nick, reason)
+		irc.setJoinFailed(chn, "Kicked by "+nick+": "+reason)
+		key = irc.config.ChannelKey(chn)
+		if ch = irc.LeaveChannel(chn); ch != nil {
+			if k := ch.Key(); k != "" {
+				key = k
+			}
+			ch.Stop()
+		}
+		irc.rejoin(chn, key)
+	}
+	irc.pruneAccounts()
+
+	irc.bot.AddEvent(
+		NewEvent(
+			UserKick,
+			&UserKickData{
+				EventBase{irc.bot, msg.Source, nick, user, host},
+				irc,
+				chn, victim, reason}))
+}
+
+// rejoin schedules joining the channel again if auto rejoin is configured,
+// up to the max attempts within the rejoin window.
+func (irc *IRC) rejoin(chn, key string) {
+	var config *ChannelConfig
+	var state *rejoinState
+	var name string
+	var delay time.Duration
+
+	config = irc.config.GetChannelConfig(chn)
+	if config == nil || !config.AutoRejoin {
+		return
+	}
+
+	irc.rejoinLock.Lock()
+	defer irc.rejoinLock.Unlock()
+	name = irc.isupport.Fold(chn)
+	state = irc.rejoins[name]
+	if state == nil || time.Since(state.last) > rejoinWindow {
+		state = new(rejoinState)
+		irc.rejoins[name] = state
+	}
+	if state.tries >= config.GetRejoinMax() {
+		irc.Logger.Printf("Not rejoining %s, gave up after %d %s",
+			chn, state.tries, sp("attempt", "attempts", state.tries))
+		return
+	}
+	state.tries++
+	state.last = time.Now()
+	delay = config.GetRejoinDelay()
+	irc.Logger.Printf("Rejoining %s in %s, attempt %d of %d",
+		chn, delay, state.tries, config.GetRejoinMax())
+	irc.scheduleJoin(chn, key, delay)
+}
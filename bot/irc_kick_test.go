@@ -0,0 +1,42 @@
+package bot
+
+import (
+	"testing"
+)
+
+func TestKick(t *testing.T) {
+	ch := make(chan bool)
+	bot := newTestBot(ch)
+
+	irc := testIRC(bot, t)
+	config := irc.config.GetChannelConfig("#candice")
+	config.AutoRejoin = true
+	config.RejoinDelay = 1
+	config.RejoinMax = 1
+
+	irc.onCommand(command("JOIN", G+"!~u@host", "#candice"))
+	irc.onCommand(command("RPL_NAMREPLY", "server", G+" = #candice :@fluter foo "+G))
+	irc.onCommand(command("KICK", "fluter!~u@host", "#candice foo :flooding"))
+	c := irc.GetChannel("#candice")
+	if _, ok := c.User("foo"); ok {
+		t.Error(c.Users())
+	}
+
+	r := testConn(irc)
+	irc.onCommand(command("KICK", "fluter!~u@host", "#candice "+G+" :bye"))
+	if irc.GetChannel("#candice") != nil {
+		t.Error("channel not left")
+	}
+	if s := readLine(r); s != "JOIN #candice\r\n" {
+		t.Error(s)
+	}
+	irc.conn = nil
+
+	irc.onCommand(command("JOIN", G+"!~u@host", "#candice"))
+	irc.onCommand(command("KICK", "fluter!~u@host", "#candice "+G+" :bye"))
+	if state := irc.rejoins["#candice"]; state == nil || state.tries != 1 {
+		t.Error(state)
+	}
+
+	delTestBot(bot, t, ch)
+}
@@ -0,0 +1,228 @@
+// Copyright 2016 Alex Fluter
+
+package bot
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidMessage = errors.New("Invalid IRC message")
+)
+
+// Message is a message received from the IRC server, in the format of
+// [@tags] [:source] command [params...] [:trailing]
+type Message struct {
+	Tags    map[string]string
+	Source  string
+	Nick    string
+	User    string
+	Host    string
+	Command string
+	Params  []string
+}
+
+// ParseMessage parses a line received from the server, without CRLF.
+func ParseMessage(line string) (*Message, error) {
+	var i int
+	var msg *Message
+
+	msg = new(Message)
+	line = strings.TrimRight(line, "\r\n")
+	line = strings.TrimLeft(line, " ")
+
+	if strings.HasPrefix(line, "@") {
+		i = strings.IndexByte(line, ' ')
+		if i == -1 {
+			return nil, ErrInvalidMessage
+		}
+		msg.Tags = parseTags(line[1:i])
+		line = strings.TrimLeft(line[i:], " ")
+	}
+
+	if strings.HasPrefix(line, ":") {
+		i = strings.IndexByte(line, ' ')
+		if i == -1 {
+			return nil, ErrInvalidMessage
+		}
+		msg.Source = line[1:i]
+		msg.Nick, msg.User, msg.Host = parseSource(msg.Source)
+		line = strings.TrimLeft(line[i:], " ")
+	}
+
+	i = strings.IndexByte(line, ' ')
+	if i == -1 {
+		msg.Command, line = line, ""
+	} else {
+		msg.Command, line = line[:i], line[i+1:]
+	}
+	if msg.Command == "" {
+		return nil, ErrInvalidMessage
+	}
+	msg.Command = strings.ToUpper(msg.Command)
+
+	for {
+		line = strings.TrimLeft(line, " ")
+		if line == "" {
+			break
+		}
+		if line[0] == ':' {
+			msg.Params = append(msg.Params, line[1:])
+			break
+		}
+		i = strings.IndexByte(line, ' ')
+		if i == -1 {
+			msg.Params = append(msg.Params, line)
+			break
+		}
+		msg.Params = append(msg.Params, line[:i])
+		line = line[i+1:]
+	}
+
+	return msg, nil
+}
+
+// parseSource splits nick!user@host, for servers the whole source
+// is returned as the nick.
+func parseSource(source string) (nick, user, host string) {
+	nick = source
+	if i := strings.IndexByte(nick, '@'); i != -1 {
+		nick, host = nick[:i], nick[i+1:]
+	}
+	if i := strings.IndexByte(nick, '!'); i != -1 {
+		nick, user = nick[:i], nick[i+1:]
+	}
+	return
+}
+
+var tagEscaper = strings.NewReplacer(
+	"\\", "\\\\",
+	";", "\\:",
+	" ", "\\s",
+	"\r", "\\r",
+	"\n", "\\n",
+)
+
+func parseTags(s string) map[string]string {
+	var tags map[string]string
+
+	tags = make(map[string]string)
+	for _, tag := range strings.Split(s, ";") {
+		if tag == "" {
+			continue
+		}
+		arr := strings.SplitN(tag, "=", 2)
+		if len(arr) == 2 {
+			tags[arr[0]] = unescapeTag(arr[1])
+		} else {
+			tags[arr[0]] = ""
+		}
+	}
+	return tags
+}
+
+func unescapeTag(s string) string {
+	var buf []byte
+
+	if strings.IndexByte(s, '\\') == -1 {
+		return s
+	}
+	buf = make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			buf = append(buf, s[i])
+			continue
+		}
+		i++
+		if i == len(s) {
+			// trailing backslash is dropped
+			break
+		}
+		switch s[i] {
+		case ':':
+			buf = append(buf, ';')
+		case 's':
+			buf = append(buf, ' ')
+		case 'r':
+			buf = append(buf, '\r')
+		case 'n':
+			buf = append(buf, '\n')
+		default:
+			buf = append(buf, s[i])
+		}
+	}
+	return string(buf)
+}
+
+// Param returns the i-th parameter, or empty string if not present.
+func (msg *Message) Param(i int) string {
+	if i < 0 || i >= len(msg.Params) {
+		return ""
+	}
+	return msg.Params[i]
+}
+
+// Trailing returns the last parameter.
+func (msg *Message) Trailing() string {
+	if len(msg.Params) == 0 {
+		return ""
+	}
+	return msg.Params[len(msg.Params)-1]
+}
+
+// Text returns the parameters starting from i joined by space.
+func (msg *Message) Text(i int) string {
+	if i < 0 || i >= len(msg.Params) {
+		return ""
+	}
+	return strings.Join(msg.Params[i:], " ")
+}
+
+// Tag returns the value of the tag and whether it is present.
+func (msg *Message) Tag(name string) (string, bool) {
+	value, ok := msg.Tags[name]
+	return value, ok
+}
+
+// Time returns the time from the server-time tag,
+// or the current time if not present.
+func (msg *Message) Time() time.Time {
+	if value, ok := msg.Tags["time"]; ok {
+		if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+			return t
+		}
+	}
+	return time.Now()
+}
+
+func (msg *Message) String() string {
+	var parts []string
+
+	if len(msg.Tags) > 0 {
+		var tags []string
+		for k, v := range msg.Tags {
+			if v == "" {
+				tags = append(tags, k)
+			} else {
+				tags = append(tags, k+"="+tagEscaper.Replace(v))
+			}
+		}
+		sort.Strings(tags)
+		parts = append(parts, "@"+strings.Join(tags, ";"))
+	}
+	if msg.Source != "" {
+		parts = append(parts, ":"+msg.Source)
+	}
+	parts = append(parts, msg.Command)
+	for i, p := range msg.Params {
+		if i == len(msg.Params)-1 &&
+			(p == "" || p[0] == ':' || strings.IndexByte(p, ' ') != -1) {
+			p = ":" + p
+		}
+		parts = append(parts, p)
+	}
+	return strings.Join(parts, " ")
+}
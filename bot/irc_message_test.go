@@ -0,0 +1,70 @@
+package bot
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMessage(t *testing.T) {
+	tests := []struct {
+		line    string
+		tags    map[string]string
+		source  string
+		nick    string
+		command string
+		params  []string
+	}{
+		{"PING :sinisalo.freenode.net", nil, "", "",
+			"PING", []string{"sinisalo.freenode.net"}},
+		{":fluter!~fluter@unaffiliated/fluter PRIVMSG #candice :hi: there :)",
+			nil, "fluter!~fluter@unaffiliated/fluter", "fluter",
+			"PRIVMSG", []string{"#candice", "hi: there :)"}},
+		{":sinisalo.freenode.net 001 candice :Welcome", nil,
+			"sinisalo.freenode.net", "sinisalo.freenode.net",
+			"001", []string{"candice", "Welcome"}},
+		{":fluter!~fluter@unaffiliated/fluter JOIN #candice", nil,
+			"fluter!~fluter@unaffiliated/fluter", "fluter",
+			"JOIN", []string{"#candice"}},
+		{"@time=2016-01-02T03:04:05.000Z;msgid=a\\sb\\:c;x :fluter!f@h MODE #candice +o  candice",
+			map[string]string{"time": "2016-01-02T03:04:05.000Z", "msgid": "a b;c", "x": ""},
+			"fluter!f@h", "fluter",
+			"MODE", []string{"#candice", "+o", "candice"}},
+		{":server PONG server :", nil, "server", "server",
+			"PONG", []string{"server", ""}},
+		{"QUIT", nil, "", "", "QUIT", nil},
+	}
+
+	for _, test := range tests {
+		msg, err := ParseMessage(test.line)
+		if err != nil {
+			t.Error(test.line, err)
+			continue
+		}
+		if test.tags != nil && !reflect.DeepEqual(msg.Tags, test.tags) {
+			t.Error(test.line, msg.Tags)
+		}
+		if msg.Source != test.source || msg.Nick != test.nick {
+			t.Error(test.line, msg.Source, msg.Nick)
+		}
+		if msg.Command != test.command {
+			t.Error(test.line, msg.Command)
+		}
+		if !reflect.DeepEqual(msg.Params, test.params) {
+			t.Errorf("%s %q", test.line, msg.Params)
+		}
+	}
+
+	for _, line := range []string{"", ":fluter", "@time=1", ":fluter "} {
+		if _, err := ParseMessage(line); err == nil {
+			t.Error("invalid message parsed:", line)
+		}
+	}
+
+	msg, _ := ParseMessage(":fluter!f@h PONG a")
+	if msg.Param(1) != "" || msg.Trailing() != "a" || msg.User != "f" || msg.Host != "h" {
+		t.Error(msg)
+	}
+	if s := msg.String(); s != ":fluter!f@h PONG a" {
+		t.Error(s)
+	}
+}
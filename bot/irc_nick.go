@@ -0,0 +1,162 @@
+// Copyright 2016 Alex Fluter
+
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ways to reclaim the primary nick when it is taken
+const (
+	NickRecoveryGhost   = "GHOST"
+	NickRecoveryRegain  = "REGAIN"
+	NickRecoveryMonitor = "MONITOR"
+)
+
+// backoff of the nick recovery through services
+const (
+	nickRetryBase = time.Minute
+	nickRetryMax  = 30 * time.Minute
+)
+
+// CurrentNick returns the nick the bot is using on the server,
+// which is BotNick unless it was taken.
+func (irc *IRC) CurrentNick() string {
+	irc.nickLock.Lock()
+	defer irc.nickLock.Unlock()
+	return irc.nick
+}
+
+func (irc *IRC) setNick(nick string) {
+	irc.nickLock.Lock()
+	irc.nick = nick
+	if irc.isupport.Equal(nick, irc.config.BotNick) {
+		irc.nickRetry = 0
+		irc.nickRetryAt = time.Time{}
+	}
+	irc.nickLock.Unlock()
+	irc.interpreter.setNick(nick)
+}
+
+// nextNick returns the nick to try after the last one was rejected,
+// the alternate nicks are used first, then BotNick with a number suffix.
+func (irc *IRC) nextNick() string {
+	var nick string
+
+	irc.nickTries++
+	if irc.nickTries <= len(irc.config.AltNicks) {
+		return irc.config.AltNicks[irc.nickTries-1]
+	}
+
+	suffix := strconv.Itoa(irc.nickTries - len(irc.config.AltNicks))
+	nick = irc.config.BotNick
+	if _, ok := irc.isupport.Get("NICKLEN"); ok {
+		nick = truncate(nick, irc.isupport.NickLen()-len(suffix))
+	}
+	return nick + suffix
+}
+
+// retryNick is called by the timer, services can reclaim the nick at any
+// time so the attempts are retried with backoff. Otherwise the nick is
+// only tried when it is seen released or MONITOR reports it offline.
+func (irc *IRC) retryNick() {
+	var method string
+
+	method = strings.ToUpper(irc.config.NickRecovery)
+	if (method != NickRecoveryGhost && method != NickRecoveryRegain) ||
+		irc.config.Identify_passwd == "" {
+		return
+	}
+	irc.nickLock.Lock()
+	if time.Now().Before(irc.nickRetryAt) {
+		irc.nickLock.Unlock()
+		return
+	}
+	irc.nickLock.Unlock()
+	irc.recoverNick()
+}
+
+// recoverNick tries to get back BotNick if the bot is using another one.
+func (irc *IRC) recoverNick() {
+	var primary string
+
+	primary = irc.config.BotNick
+	if !irc.registered || irc.IsMe(primary) {
+		return
+	}
+
+	irc.nickLock.Lock()
+	irc.nickRetry *= 2
+	if irc.nickRetry < nickRetryBase {
+		irc.nickRetry = nickRetryBase
+	} else if irc.nickRetry > nickRetryMax {
+		irc.nickRetry = nickRetryMax
+	}
+	irc.nickRetryAt = time.Now().Add(irc.nickRetry)
+	irc.nickLock.Unlock()
+
+	switch strings.ToUpper(irc.config.NickRecovery) {
+	case NickRecoveryGhost:
+		if irc.config.Identify_passwd != "" {
+			irc.sendMsg(fmt.Sprintf("PRIVMSG NickServ :GHOST %s %s",
+				primary, irc.config.Identify_passwd))
+		}
+	case NickRecoveryRegain:
+		if irc.config.Identify_passwd != "" {
+			irc.sendMsg(fmt.Sprintf("PRIVMSG NickServ :REGAIN %s %s",
+				primary, irc.config.Identify_passwd))
+			// services change the nick for us
+			return
+		}
+	}
+	irc.Logger.Printf("Trying to recover nick %s", primary)
+	irc.Nick(primary)
+}
+
+// monitorNick asks the server to notify when BotNick becomes available.
+func (irc *IRC) monitorNick() {
+	if strings.ToUpper(irc.config.NickRecovery) != NickRecoveryMonitor {
+		return
+	}
+	if _, ok := irc.isupport.Get("MONITOR"); !ok {
+		irc.Logger.Println("Server does not support MONITOR")
+		return
+	}
+	irc.sendMsg("MONITOR + " + irc.config.BotNick)
+}
+
+// format: :server 433 * Subhuti :Nickname is already in use.
+func (irc *IRC) onERR_NICKNAMEINUSE(msg *Message) {
+	var nick string
+
+	nick = msg.Param(1)
+	irc.Logger.Printf("Nick %s is not available: %s", nick, msg.Trailing())
+
+	// during registration a nick is required to continue
+	if irc.registered {
+		return
+	}
+	nick = irc.nextNick()
+	irc.setNick(nick)
+	irc.Logger.Printf("Trying nick %s", nick)
+	irc.Nick(nick)
+}
+
+// format: :server 731 Subhuti_ :Subhuti
+func (irc *IRC) onRPL_MONOFFLINE(msg *Message) {
+	for _, nick := range strings.Split(msg.Trailing(), ",") {
+		nick, _, _ = parseSource(nick)
+		if irc.isupport.Equal(nick, irc.config.BotNick) {
+			irc.Logger.Printf("Nick %s is available", nick)
+			irc.recoverNick()
+		}
+	}
+}
+
+// format: :server 730 Subhuti_ :Subhuti!~subhuti@host
+func (irc *IRC) onRPL_MONONLINE(msg *Message) {
+	irc.Logger.Printf("Monitored nicks online: %s", msg.Trailing())
+}
@@ -0,0 +1,94 @@
+package bot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNickInUse(t *testing.T) {
+	ch := make(chan bool)
+	bot := newTestBot(ch)
+
+	irc := testIRC(bot, t)
+	r := testConn(irc)
+	irc.config.AltNicks = []string{"Candice"}
+
+	go irc.onCommand(command("ERR_NICKNAMEINUSE", "server", "* "+G+" :Nickname is already in use."))
+	if s := readLine(r); s != "NICK Candice\r\n" {
+		t.Error(s)
+	}
+	go irc.onCommand(command("ERR_NICKNAMEINUSE", "server", "* Candice :Nickname is already in use."))
+	if s := readLine(r); s != "NICK "+G+"1\r\n" {
+		t.Error(s)
+	}
+
+	go irc.onCommand(command("RPL_WELCOME", "server", G+"1 :Welcome"))
+	if s := readLine(r); s != "JOIN #candice\r\n" {
+		t.Error(s)
+	}
+	if irc.CurrentNick() != G+"1" || !irc.IsMe(G+"1") || irc.IsMe(G) {
+		t.Error(irc.CurrentNick())
+	}
+
+	// the primary nick is released
+	go irc.onCommand(command("NICK", G+"!~u@host", G+"_away"))
+	if s := readLine(r); s != "NICK "+G+"\r\n" {
+		t.Error(s)
+	}
+	irc.onCommand(command("NICK", G+"1!~u@host", G))
+	if irc.CurrentNick() != G {
+		t.Error(irc.CurrentNick())
+	}
+
+	irc.conn = nil
+	irc.config.AltNicks = nil
+	delTestBot(bot, t, ch)
+}
+
+func TestNickRetry(t *testing.T) {
+	ch := make(chan bool)
+	bot := newTestBot(ch)
+	irc := testIRC(bot, t)
+	r := testConn(irc)
+	irc.registered = true
+	irc.setNick(G + "_")
+
+	// nothing to recover with
+	irc.retryNick()
+	if irc.nickRetry != 0 {
+		t.Error("retried without a recovery method")
+	}
+
+	irc.config.NickRecovery = NickRecoveryGhost
+	irc.config.Identify_passwd = "secret"
+	go irc.retryNick()
+	// the ghost is sent before the nick
+	if s := readLine(r); s != "PRIVMSG NickServ :GHOST "+G+" secret\r\n" {
+		t.Error(s)
+	}
+	if s := readLine(r); s != "NICK "+G+"\r\n" {
+		t.Error(s)
+	}
+	// backing off
+	irc.retryNick()
+	if irc.nickRetry != nickRetryBase {
+		t.Error(irc.nickRetry)
+	}
+	irc.nickRetryAt = time.Now()
+	go irc.retryNick()
+	readLine(r)
+	readLine(r)
+	if irc.nickRetry != 2*nickRetryBase {
+		t.Error(irc.nickRetry)
+	}
+
+	irc.setNick(G)
+	if irc.nickRetry != 0 {
+		t.Error("backoff not reset")
+	}
+
+	irc.conn = nil
+	irc.config.NickRecovery = ""
+	irc.config.Identify_passwd = ""
+	delTestBot(bot, t, ch)
+}
@@ -0,0 +1,233 @@
+// Copyright 2016 Alex Fluter
+
+package bot
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotConnected = errors.New("Not connected")
+)
+
+// defaults of the flood protection, the bucket holds SendBurst lines
+// and refills SendRate lines per second
+const (
+	DefaultSendBurst    = 5
+	DefaultSendRate     = 0.5
+	DefaultSendQueueMax = 10
+)
+
+// send lanes in the order of priority
+const (
+	laneProtocol = iota
+	laneReply
+	numLanes
+)
+
+type sendItem struct {
+	line   string
+	target string
+}
+
+// sendQueue is the outbound queue of a network, lines are taken
+// by priority and limited by a token bucket.
+type sendQueue struct {
+	sync.Mutex
+	cond   *sync.Cond
+	lanes  [numLanes][]*sendItem
+	closed bool
+
+	burst  float64
+	rate   float64
+	tokens float64
+	last   time.Time
+
+	// max queued replies per target, older ones are dropped
+	max     int
+	dropped uint
+}
+
+func newSendQueue(burst int, rate float64, max int) *sendQueue {
+	q := new(sendQueue)
+	q.cond = sync.NewCond(q)
+	if burst <= 0 {
+		burst = DefaultSendBurst
+	}
+	if rate <= 0 {
+		rate = DefaultSendRate
+	}
+	if max <= 0 {
+		max = DefaultSendQueueMax
+	}
+	q.burst = float64(burst)
+	q.rate = rate
+	q.tokens = q.burst
+	q.last = time.Now()
+	q.max = max
+	return q
+}
+
+// push queues a line, replies identical to a queued one are coalesced.
+func (q *sendQueue) push(lane int, item *sendItem) {
+	q.Lock()
+	defer q.Unlock()
+	if q.closed {
+		return
+	}
+	if lane == laneReply {
+		var n int
+		var first = -1
+		for i, it := range q.lanes[lane] {
+			if it.target != item.target {
+				continue
+			}
+			if it.line == item.line {
+				return
+			}
+			if first == -1 {
+				first = i
+			}
+			n++
+		}
+		if n >= q.max {
+			q.remove(lane, first)
+			q.dropped++
+		}
+	}
+	q.lanes[lane] = append(q.lanes[lane], item)
+	q.cond.Signal()
+}
+
+func (q *sendQueue) remove(lane, i int) {
+	items := q.lanes[lane]
+	copy(items[i:], items[i+1:])
+	items[len(items)-1] = nil
+	q.lanes[lane] = items[:len(items)-1]
+}
+
+func (q *sendQueue) size() int {
+	var n int
+	for _, items := range q.lanes {
+		n += len(items)
+	}
+	return n
+}
+
+// pop waits for a line and a token, returns nil when the queue is closed.
+func (q *sendQueue) pop() *sendItem {
+	q.Lock()
+	defer q.Unlock()
+	for {
+		for !q.closed && q.size() == 0 {
+			q.cond.Wait()
+		}
+		if q.closed {
+			return nil
+		}
+		now := time.Now()
+		q.tokens += now.Sub(q.last).Seconds() * q.rate
+		if q.tokens > q.burst {
+			q.tokens = q.burst
+		}
+		q.last = now
+		if q.tokens < 1 {
+			wait := time.Duration((1 - q.tokens) / q.rate * float64(time.Second))
+			q.Unlock()
+			time.Sleep(wait)
+			q.Lock()
+			continue
+		}
+		// lines queued while waiting for the token are considered
+		for lane := range q.lanes {
+			if len(q.lanes[lane]) > 0 {
+				item := q.lanes[lane][0]
+				q.remove(lane, 0)
+				q.tokens--
+				return item
+			}
+		}
+	}
+}
+
+// drop removes the queued replies to target, returns the number dropped.
+func (q *sendQueue) drop(target string) int {
+	var n int
+
+	q.Lock()
+	defer q.Unlock()
+	items := q.lanes[laneReply][:0]
+	for _, it := range q.lanes[laneReply] {
+		if it.target == target {
+			n++
+			continue
+		}
+		items = append(items, it)
+	}
+	q.lanes[laneReply] = items
+	q.dropped += uint(n)
+	return n
+}
+
+func (q *sendQueue) clear() {
+	q.Lock()
+	for lane := range q.lanes {
+		q.lanes[lane] = nil
+	}
+	q.Unlock()
+}
+
+func (q *sendQueue) close() {
+	q.Lock()
+	q.closed = true
+	q.cond.Broadcast()
+	q.Unlock()
+}
+
+func (q *sendQueue) stats() (queued int, dropped uint) {
+	q.Lock()
+	defer q.Unlock()
+	return q.size(), q.dropped
+}
+
+// classify returns the lane of the line and the target of replies,
+// urgent lines like PONG bypass the queue. Messages to NickServ are
+// protocol, the commands following them depend on them.
+func classify(line string) (lane int, target string, urgent bool) {
+	arr := strings.SplitN(line, " ", 3)
+	switch strings.ToUpper(arr[0]) {
+	case "PONG", "QUIT":
+		return laneProtocol, "", true
+	case "PRIVMSG", "NOTICE":
+		if len(arr) > 1 {
+			target = arr[1]
+		}
+		if strings.EqualFold(target, "NickServ") {
+			return laneProtocol, "", false
+		}
+		return laneReply, target, false
+	}
+	return laneProtocol, "", false
+}
+
+// DropQueue drops the replies to target not sent yet.
+func (irc *IRC) DropQueue(target string) int {
+	return irc.sendq.drop(target)
+}
+
+func (irc *IRC) sendLoop() {
+	var item *sendItem
+
+	for {
+		item = irc.sendq.pop()
+		if item == nil {
+			break
+		}
+		irc.write(item.line)
+	}
+	irc.wait.Done()
+	irc.Logger.Print("IRC send loop exited")
+}
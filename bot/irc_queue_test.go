@@ -0,0 +1,57 @@
+package bot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSendQueue(t *testing.T) {
+	q := newSendQueue(2, 20, 2)
+
+	q.push(laneReply, &sendItem{"PRIVMSG #a :1", "#a"})
+	q.push(laneReply, &sendItem{"PRIVMSG #a :1", "#a"})
+	q.push(laneReply, &sendItem{"PRIVMSG #a :2", "#a"})
+	q.push(laneReply, &sendItem{"PRIVMSG #a :3", "#a"})
+	q.push(laneReply, &sendItem{"PRIVMSG #b :1", "#b"})
+	q.push(laneProtocol, &sendItem{"JOIN #c", ""})
+
+	if queued, dropped := q.stats(); queued != 4 || dropped != 1 {
+		t.Error(queued, dropped)
+	}
+
+	start := time.Now()
+	for _, line := range []string{"JOIN #c", "PRIVMSG #a :2", "PRIVMSG #a :3"} {
+		if item := q.pop(); item.line != line {
+			t.Error(item.line)
+		}
+	}
+	// the burst is used up, the third line waits for a token
+	if d := time.Since(start); d < 40*time.Millisecond {
+		t.Error(d)
+	}
+
+	if n := q.drop("#b"); n != 1 {
+		t.Error(n)
+	}
+	q.close()
+	if item := q.pop(); item != nil {
+		t.Error(item.line)
+	}
+
+	for _, c := range []struct {
+		line   string
+		lane   int
+		target string
+		urgent bool
+	}{
+		{"PONG :server", laneProtocol, "", true},
+		{"NOTICE foo :hi", laneReply, "foo", false},
+		{"MODE #c +o foo", laneProtocol, "", false},
+		{"PRIVMSG NickServ :GHOST foo x", laneProtocol, "", false},
+	} {
+		lane, target, urgent := classify(c.line)
+		if lane != c.lane || target != c.target || urgent != c.urgent {
+			t.Error(c.line, lane, target, urgent)
+		}
+	}
+}
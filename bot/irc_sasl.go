@@ -0,0 +1,173 @@
+// Copyright 2016 Alex Fluter
+
+package bot
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// saslChunk is the maximum size of an AUTHENTICATE payload
+const saslChunk = 400
+
+var (
+	ErrSaslFailed = errors.New("SASL authentication failed")
+)
+
+func (irc *IRC) saslEnabled() bool {
+	return irc.config.GetSaslMech() != ""
+}
+
+// saslSupported checks that the server offers the configured mechanism.
+func (irc *IRC) saslSupported() bool {
+	mech := irc.config.GetSaslMech()
+	mechs, ok := irc.caps.value(CapSasl)
+	if !ok {
+		irc.Logger.Println("SASL is not supported by server, not authenticating")
+		return false
+	}
+	// older servers do not list the mechanisms
+	if mechs == "" {
+		return true
+	}
+	for _, m := range strings.Split(mechs, ",") {
+		if strings.ToUpper(m) == mech {
+			return true
+		}
+	}
+	irc.Logger.Printf("SASL mechanism %s is not supported by server, available: %s",
+		mech, mechs)
+	return false
+}
+
+// saslStart begins authentication if sasl is in the acknowledged caps,
+// returns false if there is nothing to authenticate.
+func (irc *IRC) saslStart(acked []string) bool {
+	var found bool
+
+	for _, cap := range acked {
+		if cap == CapSasl {
+			found = true
+			break
+		}
+	}
+	if !found || !irc.saslEnabled() {
+		return false
+	}
+
+	mech := irc.config.GetSaslMech()
+	if mech == SaslExternal && (irc.server == nil || !irc.server.Ssl) {
+		irc.Logger.Println("SASL EXTERNAL needs a client certificate over TLS")
+	}
+	irc.Logger.Printf("Authenticating as %s using %s",
+		irc.config.GetSaslAccount(), mech)
+	irc.sendMsg("AUTHENTICATE " + mech)
+	return true
+}
+
+// saslSend sends the payload base64 encoded, split into chunks.
+func (irc *IRC) saslSend(data []byte) error {
+	var err error
+	var last int
+
+	enc := base64.StdEncoding.EncodeToString(data)
+	if enc == "" {
+		return irc.sendMsg("AUTHENTICATE +")
+	}
+	for len(enc) > 0 {
+		last = len(enc)
+		if last > saslChunk {
+			last = saslChunk
+		}
+		err = irc.sendMsg("AUTHENTICATE " + enc[:last])
+		if err != nil {
+			return err
+		}
+		enc = enc[last:]
+	}
+	// a full chunk needs to be terminated
+	if last == saslChunk {
+		return irc.sendMsg("AUTHENTICATE +")
+	}
+	return nil
+}
+
+// saslFail aborts the registration, it is not retried as
+// the credentials would be refused again.
+func (irc *IRC) saslFail(reason string) {
+	irc.authErr = ErrSaslFailed
+	irc.Logger.Printf("%s: %s", ErrSaslFailed, reason)
+	irc.Logger.Println("Registration aborted, check the SASL configuration")
+	irc.Quit(ErrSaslFailed.Error())
+	irc.disconnect()
+}
+
+// format: AUTHENTICATE +
+func (irc *IRC) onAuthenticate(msg *Message) {
+	if msg.Param(0) != "+" {
+		irc.Logger.Printf("Unexpected SASL challenge: %s", msg.Param(0))
+		irc.sendMsg("AUTHENTICATE *")
+		return
+	}
+
+	switch irc.config.GetSaslMech() {
+	case SaslPlain:
+		account := irc.config.GetSaslAccount()
+		payload := strings.Join([]string{account, account,
+			irc.config.Identify_passwd}, "\000")
+		irc.saslSend([]byte(payload))
+	case SaslExternal:
+		irc.saslSend(nil)
+	default:
+		irc.Logger.Printf("Unsupported SASL mechanism %s",
+			irc.config.GetSaslMech())
+		irc.sendMsg("AUTHENTICATE *")
+	}
+}
+
+// format: 900 candice candice!gbot@unaffiliated/fluter/bot/candice candice :You are now logged in as candice
+func (irc *IRC) onRPL_LOGGEDIN(msg *Message) {
+	irc.account = msg.Param(2)
+	irc.Logger.Println(msg.Trailing())
+}
+
+func (irc *IRC) onRPL_LOGGEDOUT(msg *Message) {
+	irc.account = ""
+	irc.Logger.Println(msg.Trailing())
+}
+
+// format: 903 candice :SASL authentication successful
+func (irc *IRC) onRPL_SASLSUCCESS(msg *Message) {
+	irc.Logger.Println(msg.Trailing())
+	irc.capEnd()
+}
+
+// format: 904 candice :SASL authentication failed
+func (irc *IRC) onERR_SASLFAIL(msg *Message) {
+	irc.saslFail(msg.Trailing())
+}
+
+// format: 905 candice :SASL message too long
+func (irc *IRC) onERR_SASLTOOLONG(msg *Message) {
+	irc.saslFail(msg.Trailing())
+}
+
+func (irc *IRC) onERR_NICKLOCKED(msg *Message) {
+	irc.saslFail(msg.Trailing())
+}
+
+func (irc *IRC) onERR_SASLABORTED(msg *Message) {
+	irc.Logger.Println(msg.Trailing())
+	irc.capEnd()
+}
+
+func (irc *IRC) onERR_SASLALREADY(msg *Message) {
+	irc.Logger.Println(msg.Trailing())
+	irc.capEnd()
+}
+
+// format: 908 candice PLAIN,EXTERNAL :are available SASL mechanisms
+func (irc *IRC) onRPL_SASLMECHS(msg *Message) {
+	irc.Logger.Println(msg.Text(1))
+}
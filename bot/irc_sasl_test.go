@@ -0,0 +1,97 @@
+package bot
+
+import (
+	"encoding/base64"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestSaslPlain(t *testing.T) {
+	ch := make(chan bool)
+	bot := newTestBot(ch)
+
+	irc := testIRC(bot, t)
+	r := testConn(irc)
+	irc.config.Identify_passwd = "secret"
+	irc.caps.reset()
+
+	go irc.onCommand(command("CAP", "server", "* LS :sasl=EXTERNAL,PLAIN"))
+	if s := readLine(r); s != "CAP REQ :sasl\r\n" {
+		t.Error(s)
+	}
+
+	go irc.onCommand(command("CAP", "server", G+" ACK :sasl"))
+	if s := readLine(r); s != "AUTHENTICATE PLAIN\r\n" {
+		t.Error(s)
+	}
+
+	go irc.onCommand(command("AUTHENTICATE", "", "+"))
+	payload := base64.StdEncoding.EncodeToString([]byte(G + "\000" + G + "\000secret"))
+	if s := readLine(r); s != "AUTHENTICATE "+payload+"\r\n" {
+		t.Error(s)
+	}
+
+	go irc.onCommand(command("RPL_SASLSUCCESS", "server", G+" :SASL authentication successful"))
+	if s := readLine(r); s != "CAP END\r\n" {
+		t.Error(s)
+	}
+
+	irc.conn = nil
+	irc.config.Identify_passwd = ""
+	delTestBot(bot, t, ch)
+}
+
+func TestSaslFail(t *testing.T) {
+	for _, code := range []string{"ERR_SASLFAIL", "ERR_SASLTOOLONG"} {
+		ch := make(chan bool)
+		bot := newTestBot(ch)
+
+		irc := testIRC(bot, t)
+		r := testConn(irc)
+		irc.config.AutoConnect = true
+		irc.timer = time.NewTicker(Ping_interval)
+		irc.wait.Add(1)
+		go irc.runTimer()
+
+		done := make(chan bool)
+		go func() {
+			irc.onCommand(command(code, "server", G+" :SASL authentication failed"))
+			done <- true
+		}()
+		if s := readLine(r); s != "QUIT :"+ErrSaslFailed.Error()+"\r\n" {
+			t.Error(code, s)
+		}
+		if _, err := r.Read(make([]byte, 1)); err != io.EOF {
+			t.Error(code, "not disconnected", err)
+		}
+		<-done
+		// the credentials would be refused again
+		if irc.authErr != ErrSaslFailed || irc.autoReconnect() {
+			t.Error(code, "reconnecting after", irc.authErr)
+		}
+
+		irc.config.AutoConnect = false
+		delTestBot(bot, t, ch)
+	}
+}
+
+func TestSaslMech(t *testing.T) {
+	config := &IRCConfig{TLSCert: "bot.pem"}
+	// CertFP only
+	if mech := config.GetSaslMech(); mech != "" {
+		t.Error(mech)
+	}
+	config.Identify_passwd = "secret"
+	if mech := config.GetSaslMech(); mech != SaslPlain {
+		t.Error(mech)
+	}
+	config.SaslCert = "sasl.pem"
+	if mech := config.GetSaslMech(); mech != SaslExternal {
+		t.Error(mech)
+	}
+	config.SaslMech = "plain"
+	if mech := config.GetSaslMech(); mech != SaslPlain {
+		t.Error(mech)
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright 2016 Alex Fluter
+
+package bot
+
+import (
+	"errors"
+	"strings"
+)
+
+const (
+	// DefaultMaxLines is the number of lines of a reply sent at once,
+	// the rest is sent on the "more" command.
+	DefaultMaxLines = 3
+	// maxHostLen is used when the bot's host is not known yet
+	maxHostLen = 63
+	// minTextLen keeps the splitting going with very long targets
+	minTextLen = 32
+
+	moreMark = " (more)"
+)
+
+var (
+	ErrNoMore = errors.New("No more lines")
+)
+
+// pendingText is the rest of a long reply waiting for "more".
+type pendingText struct {
+	cmd    string
+	target string
+	lead   string
+	lines  []string
+}
+
+// setSource records the prefix of the bot as seen by others,
+// from its own JOIN.
+func (irc *IRC) setSource(source string) {
+	irc.moreLock.Lock()
+	irc.source = source
+	irc.moreLock.Unlock()
+}
+
+// sourceLen returns the length of nick!user@host the server prepends
+// to our messages, parts not known yet are estimated.
+func (irc *IRC) sourceLen() int {
+	var user, host string
+
+	irc.moreLock.Lock()
+	_, user, host = parseSource(irc.source)
+	irc.moreLock.Unlock()
+	if user == "" {
+		user = "~" + irc.config.Username
+	}
+	if irc.cloak != "" {
+		host = irc.cloak
+	}
+	if host == "" {
+		return len(irc.CurrentNick()) + 1 + len(user) + 1 + maxHostLen
+	}
+	return len(irc.CurrentNick()) + 1 + len(user) + 1 + len(host)
+}
+
+// textLen returns the max length of text in a line of cmd to target.
+func (irc *IRC) textLen(cmd, target, lead string) int {
+	// :nick!user@host PRIVMSG target :lead text\r\n
+	n := irc.isupport.LineLen() - len(crlf) -
+		(1 + irc.sourceLen() + 1) -
+		len(cmd+" "+target+" :"+lead)
+	if n < minTextLen {
+		n = minTextLen
+	}
+	return n
+}
+
+// splitText splits text into lines of at most n bytes, on line breaks
+// and word boundaries, without splitting runes.
+func splitText(text string, n int) []string {
+	var lines []string
+
+	text = strings.Replace(text, "\r\n", "\n", -1)
+	for _, para := range strings.Split(text, "\n") {
+		para = strings.TrimRight(para, " ")
+		for len(para) > n {
+			i := strings.LastIndexByte(para[:n+1], ' ')
+			if i <= 0 {
+				// a word longer than a line
+				i = len(truncate(para, n))
+				if i == 0 {
+					i = n
+				}
+				lines = append(lines, para[:i])
+				para = para[i:]
+			} else {
+				lines = append(lines, para[:i])
+				para = strings.TrimLeft(para[i:], " ")
+			}
+		}
+		if para != "" {
+			lines = append(lines, para)
+		}
+	}
+	return lines
+}
+
+func (irc *IRC) maxLines() int {
+	if irc.config.MaxLines > 0 {
+		return irc.config.MaxLines
+	}
+	return DefaultMaxLines
+}
+
+// sendText sends text to the target in lines fitting the line length,
+// lines over the limit are kept for the "more" command.
+func (irc *IRC) sendText(cmd, to, target, lead, text string) error {
+	var lines []string
+	var n int
+
+	n = irc.textLen(cmd, target, lead)
+	lines = splitText(text, n)
+	if len(lines) > irc.maxLines() {
+		lines = irc.keepMore(&pendingText{cmd, target, lead, lines}, to, n)
+	}
+	return irc.sendLines(cmd, target, lead, lines)
+}
+
+// pageLines returns the first max lines to send now, with the more mark
+// on the last one kept within n bytes, and the rest.
+func pageLines(lines []string, max, n int) (now, rest []string) {
+	var last string
+
+	if len(lines) <= max {
+		return lines, nil
+	}
+	now = append(now, lines[:max-1]...)
+	rest = lines[max:]
+	last = lines[max-1]
+	if len(last)+len(moreMark) > n {
+		arr := splitText(last, n-len(moreMark))
+		last = arr[0]
+		rest = append(arr[1:len(arr):len(arr)], rest...)
+	}
+	now = append(now, last+moreMark)
+	return now, rest
+}
+
+// keepMore stores the lines after the limit for to,
+// returns the lines to send now.
+func (irc *IRC) keepMore(pending *pendingText, to string, n int) []string {
+	var now, rest []string
+
+	now, rest = pageLines(pending.lines, irc.maxLines(), n)
+	pending.lines = rest
+	irc.moreLock.Lock()
+	irc.more[irc.isupport.Fold(to)] = pending
+	irc.moreLock.Unlock()
+	return now
+}
+
+func (irc *IRC) sendLines(cmd, target, lead string, lines []string) error {
+	var err error
+	for _, line := range lines {
+		err = irc.sendMsg(cmd + " " + target + " :" + lead + line)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// More sends the next lines of the last long reply to target.
+func (irc *IRC) More(to string) error {
+	var pending *pendingText
+	var lines []string
+	var key string
+
+	key = irc.isupport.Fold(to)
+	irc.moreLock.Lock()
+	pending = irc.more[key]
+	delete(irc.more, key)
+	irc.moreLock.Unlock()
+	if pending == nil || len(pending.lines) == 0 {
+		return ErrNoMore
+	}
+
+	lines = pending.lines
+	if len(lines) > irc.maxLines() {
+		n := irc.textLen(pending.cmd, pending.target, pending.lead)
+		lines = irc.keepMore(pending, to, n)
+	}
+	return irc.sendLines(pending.cmd, pending.target, pending.lead, lines)
+}
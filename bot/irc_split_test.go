@@ -0,0 +1,104 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplitText(t *testing.T) {
+	lines := splitText("hello world foo\r\nbar", 11)
+	if len(lines) != 3 || lines[0] != "hello world" || lines[1] != "foo" || lines[2] != "bar" {
+		t.Error(lines)
+	}
+
+	long := strings.Repeat("世界", 10)
+	for _, line := range splitText(long, 10) {
+		if len(line) > 10 || !utf8.ValidString(line) {
+			t.Error(line)
+		}
+	}
+}
+
+func TestPrivmsgMore(t *testing.T) {
+	ch := make(chan bool)
+	bot := newTestBot(ch)
+
+	irc := testIRC(bot, t)
+	r := testConn(irc)
+	irc.config.MaxLines = 2
+	irc.setSource(G + "!~subhuti@example.com")
+
+	text := strings.Repeat("lorem ipsum dolor ", 100)
+	go irc.Privmsg("#candice", text)
+
+	max := 512 - len(":"+G+"!~subhuti@example.com ")
+	var got []string
+	for i := 0; i < 2; i++ {
+		s := readLine(r)
+		if len(s) > max || !strings.HasPrefix(s, "PRIVMSG #candice :") {
+			t.Error(len(s), s)
+		}
+		got = append(got, s)
+	}
+	if !strings.HasSuffix(got[1], moreMark+"\r\n") {
+		t.Error(got[1])
+	}
+
+	go irc.More("#Candice")
+	if s := readLine(r); len(s) > max || !strings.HasPrefix(s, "PRIVMSG #candice :") {
+		t.Error(s)
+	}
+	readLine(r)
+	for irc.More("#candice") != ErrNoMore {
+		readLine(r)
+	}
+
+	irc.conn = nil
+	irc.config.MaxLines = 0
+	delTestBot(bot, t, ch)
+}
+
+func longCmd(*MessageRequest, string) (string, error) {
+	return strings.Repeat("lorem ipsum dolor ", 100), nil
+}
+
+func TestCommandMore(t *testing.T) {
+	ch := make(chan bool)
+	bot := newTestBot(ch)
+
+	irc := testIRC(bot, t)
+	r := testConn(irc)
+	irc.config.MaxLines = 2
+	irc.interpreter.RegisterCommand("long", longCmd)
+
+	irc.onCommand(command("PRIVMSG", "foo!~u@host", "#candice :"+G+": long"))
+	if s := readLine(r); !strings.HasPrefix(s, "PRIVMSG #candice :foo: lorem") {
+		t.Error(s)
+	}
+	if s := readLine(r); !strings.HasSuffix(s, moreMark+"\r\n") {
+		t.Error(s)
+	}
+
+	// the rest is kept for the channel, not the nick
+	irc.onCommand(command("PRIVMSG", "bar!~u@host", "#candice :?more"))
+	if s := readLine(r); !strings.HasPrefix(s, "PRIVMSG #candice :") || strings.Contains(s, "No more") {
+		t.Error(s)
+	}
+	if s := readLine(r); !strings.HasSuffix(s, moreMark+"\r\n") {
+		t.Error(s)
+	}
+	for i := 0; ; i++ {
+		irc.onCommand(command("PRIVMSG", "foo!~u@host", "#candice :?more"))
+		if s := readLine(r); s == "PRIVMSG #candice :No more lines\r\n" {
+			break
+		}
+		if i > 10 {
+			t.Fatal("more never ends")
+		}
+	}
+
+	irc.conn = nil
+	irc.config.MaxLines = 0
+	delTestBot(bot, t, ch)
+}
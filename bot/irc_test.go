@@ -36,6 +36,19 @@ func TestNickRe(t *testing.T) {
 	matchNickRe(s, t)
 }
 
+// command builds a message as if it is received from the server
+func command(cmd, prefix, param string) *Message {
+	line := cmd + " " + param
+	if prefix != "" {
+		line = ":" + prefix + " " + line
+	}
+	msg, err := ParseMessage(line)
+	if err != nil {
+		panic(err)
+	}
+	return msg
+}
+
 func newTestBot(ch chan bool) *Bot {
 	config := &BotConfig{
 		Trigger: '/',
@@ -48,6 +61,7 @@ func newTestBot(ch chan bool) *Bot {
 				AutoConnect: false,
 				Trigger:     '?',
 				BotNick:     G,
+				SendRate:    100,
 				Channels: []*ChannelConfig{
 					&ChannelConfig{
 						Name:    "#candice",
@@ -76,6 +90,24 @@ func delTestBot(bot *Bot, t *testing.T, ch chan bool) {
 	}
 }
 
+// testIRC returns the IRC module of the test bot.
+func testIRC(bot *Bot, t *testing.T) *IRC {
+	for _, mod := range bot.modules {
+		if irc, ok := mod.(*IRC); ok {
+			return irc
+		}
+	}
+	t.Fatal("no IRC module")
+	return nil
+}
+
+// testConn connects irc to a pipe and returns the server side.
+func testConn(irc *IRC) net.Conn {
+	r, w := net.Pipe()
+	irc.setConn(w)
+	return r
+}
+
 func readLog(r io.Reader, t *testing.T) {
 	buf := make([]byte, 1024)
 	n, _ := r.Read(buf)
@@ -100,70 +132,61 @@ func TestIRCCommandChannel(t *testing.T) {
 		t.Fail()
 	}
 
-	var irc *IRC
-	for _, mod := range bot.modules {
-		if _, ok := mod.(*IRC); ok {
-			irc = mod.(*IRC)
-			break
-		}
-	}
-	if irc == nil {
-		t.Fail()
-	}
+	irc := testIRC(bot, t)
 
 	var counter int
 	c = make(chan bool)
 	pc = &counter
 	irc.interpreter.RegisterCommand("bash", bashCmd)
 
-	irc.onCommand("PRIVMSG", "", "#candice :hello")
+	irc.onCommand(command("PRIVMSG", "", "#candice :hello"))
 	// channel messages
-	irc.onCommand("PRIVMSG", "", "#candice :bash")
+	irc.onCommand(command("PRIVMSG", "", "#candice :bash"))
 	// call with trigger
-	irc.onCommand("PRIVMSG", "", "#candice :?bash")
+	irc.onCommand(command("PRIVMSG", "", "#candice :?bash"))
 	<-c
-	irc.onCommand("PRIVMSG", "", "#candice :!bash")
-	irc.onCommand("PRIVMSG", "", "#candice :?bash arg1 arg2")
+	irc.onCommand(command("PRIVMSG", "", "#candice :!bash"))
+	irc.onCommand(command("PRIVMSG", "", "#candice :?bash arg1 arg2"))
 	<-c
-	irc.onCommand("PRIVMSG", "", "#candice :,bash arg1 arg2")
+	irc.onCommand(command("PRIVMSG", "", "#candice :,bash arg1 arg2"))
 	// call with nick, 1st form
-	irc.onCommand("PRIVMSG", "", "#candice :Subhuti: bash")
+	irc.onCommand(command("PRIVMSG", "", "#candice :Subhuti: bash"))
 	<-c
-	irc.onCommand("PRIVMSG", "", "#candice :Subhuti, bash")
+	irc.onCommand(command("PRIVMSG", "", "#candice :Subhuti, bash"))
 	<-c
-	irc.onCommand("PRIVMSG", "", "#candice :Subhuti; bash")
+	irc.onCommand(command("PRIVMSG", "", "#candice :Subhuti; bash"))
 	<-c
-	irc.onCommand("PRIVMSG", "", "#candice :Subhuti. bash")
+	irc.onCommand(command("PRIVMSG", "", "#candice :Subhuti. bash"))
 	<-c
-	irc.onCommand("PRIVMSG", "", "#candice :Subhuti! bash")
-	irc.onCommand("PRIVMSG", "", "#candice :Subhuti: bash arg1 arg2")
+	irc.onCommand(command("PRIVMSG", "", "#candice :Subhuti! bash"))
+	irc.onCommand(command("PRIVMSG", "", "#candice :Subhuti: bash arg1 arg2"))
 	<-c
-	irc.onCommand("PRIVMSG", "", "#candice :Subhuti, bash arg1 arg2")
+	irc.onCommand(command("PRIVMSG", "", "#candice :Subhuti, bash arg1 arg2"))
 	<-c
-	irc.onCommand("PRIVMSG", "", "#candice :Subhuti; bash arg1 arg2")
+	irc.onCommand(command("PRIVMSG", "", "#candice :Subhuti; bash arg1 arg2"))
 	<-c
-	irc.onCommand("PRIVMSG", "", "#candice :Subhuti. bash arg1 arg2")
+	irc.onCommand(command("PRIVMSG", "", "#candice :Subhuti. bash arg1 arg2"))
 	<-c
-	irc.onCommand("PRIVMSG", "", "#candice :Subhuti! bash arg1 arg2")
+	irc.onCommand(command("PRIVMSG", "", "#candice :Subhuti! bash arg1 arg2"))
 	// call with nick, 2nd form
-	irc.onCommand("PRIVMSG", "", "#candice :bash, Subhuti")
+	irc.onCommand(command("PRIVMSG", "", "#candice :bash, Subhuti"))
 	<-c
-	irc.onCommand("PRIVMSG", "", "#candice :bash. Subhuti")
+	irc.onCommand(command("PRIVMSG", "", "#candice :bash. Subhuti"))
 	<-c
-	irc.onCommand("PRIVMSG", "", "#candice :bash: Subhuti")
+	irc.onCommand(command("PRIVMSG", "", "#candice :bash: Subhuti"))
 	<-c
-	irc.onCommand("PRIVMSG", "", "#candice :bash; Subhuti")
+	irc.onCommand(command("PRIVMSG", "", "#candice :bash; Subhuti"))
 	<-c
-	irc.onCommand("PRIVMSG", "", "#candice :bash! Subhuti")
-	irc.onCommand("PRIVMSG", "", "#candice :bash arg1 arg2, Subhuti")
+	irc.onCommand(command("PRIVMSG", "", "#candice :bash! Subhuti"))
+	irc.onCommand(command("PRIVMSG", "", "#candice :bash arg1 arg2, Subhuti"))
 	<-c
-	irc.onCommand("PRIVMSG", "", "#candice :bash arg1 arg2. Subhuti")
+	irc.onCommand(command("PRIVMSG", "", "#candice :bash arg1 arg2. Subhuti"))
 	<-c
-	irc.onCommand("PRIVMSG", "", "#candice :bash arg1 arg2: Subhuti")
+	irc.onCommand(command("PRIVMSG", "", "#candice :bash arg1 arg2: Subhuti"))
 	<-c
-	irc.onCommand("PRIVMSG", "", "#candice :bash arg1 arg2; Subhuti")
+	irc.onCommand(command("PRIVMSG", "", "#candice :bash arg1 arg2; Subhuti"))
 	<-c
-	irc.onCommand("PRIVMSG", "", "#candice :bash arg1 arg2! Subhuti")
+	irc.onCommand(command("PRIVMSG", "", "#candice :bash arg1 arg2! Subhuti"))
 
 	if counter != 18 {
 		t.Fail()
@@ -182,33 +205,24 @@ func TestIRCCommandPrivate(t *testing.T) {
 		t.Fail()
 	}
 
-	var irc *IRC
-	for _, mod := range bot.modules {
-		if _, ok := mod.(*IRC); ok {
-			irc = mod.(*IRC)
-			break
-		}
-	}
-	if irc == nil {
-		t.Fail()
-	}
+	irc := testIRC(bot, t)
 
 	var counter int
 	c = make(chan bool)
 	pc = &counter
 	irc.interpreter.RegisterCommand("bash", bashCmd)
 
-	irc.onCommand("PRIVMSG", "", "foo :hello")
+	irc.onCommand(command("PRIVMSG", "", "foo :hello"))
 	// channel messages
-	irc.onCommand("PRIVMSG", "", "foo :bash")
+	irc.onCommand(command("PRIVMSG", "", "foo :bash"))
 	<-c
 	// call with trigger
-	irc.onCommand("PRIVMSG", "", "foo :?bash")
+	irc.onCommand(command("PRIVMSG", "", "foo :?bash"))
 	<-c
-	irc.onCommand("PRIVMSG", "", "foo :!bash")
-	irc.onCommand("PRIVMSG", "", "foo :?bash arg1 arg2")
+	irc.onCommand(command("PRIVMSG", "", "foo :!bash"))
+	irc.onCommand(command("PRIVMSG", "", "foo :?bash arg1 arg2"))
 	<-c
-	irc.onCommand("PRIVMSG", "", "foo :,bash arg1 arg2")
+	irc.onCommand(command("PRIVMSG", "", "foo :,bash arg1 arg2"))
 
 	if counter != 3 {
 		t.Fail()
@@ -227,21 +241,11 @@ func TestIRCURLParserGetTitle(t *testing.T) {
 		t.Fail()
 	}
 
-	var irc *IRC
-	for _, mod := range bot.modules {
-		if _, ok := mod.(*IRC); ok {
-			irc = mod.(*IRC)
-			break
-		}
-	}
-	if irc == nil {
-		t.Fail()
-	}
+	irc := testIRC(bot, t)
 
-	r, w := net.Pipe()
-	irc.conn = w
+	r := testConn(irc)
 
-	irc.onCommand("PRIVMSG", "foo", "#candice :https://www.bing.com")
+	irc.onCommand(command("PRIVMSG", "foo", "#candice :https://www.bing.com"))
 	readLog(r, t)
 
 	irc.conn = nil
@@ -255,28 +259,18 @@ func TestIRCURLParserPaste(t *testing.T) {
 		t.Fail()
 	}
 
-	var irc *IRC
-	for _, mod := range bot.modules {
-		if _, ok := mod.(*IRC); ok {
-			irc = mod.(*IRC)
-			break
-		}
-	}
-	if irc == nil {
-		t.Fail()
-	}
+	irc := testIRC(bot, t)
 
-	r, w := net.Pipe()
-	irc.conn = w
+	r := testConn(irc)
 
-	irc.onCommand("PRIVMSG", "foo", "#candice :http://ideone.com/FllowW")
+	irc.onCommand(command("PRIVMSG", "foo", "#candice :http://ideone.com/FllowW"))
 	readLog(r, t)
 
-	irc.onCommand("PRIVMSG", "foo", "#candice :http://sprunge.us/RWOP")
+	irc.onCommand(command("PRIVMSG", "foo", "#candice :http://sprunge.us/RWOP"))
 	readLog(r, t)
 
 	// no repaste and no reply
-	irc.onCommand("PRIVMSG", "foo", "#candice :http://sprunge.us/UjQf")
+	irc.onCommand(command("PRIVMSG", "foo", "#candice :http://sprunge.us/UjQf"))
 
 	irc.conn = nil
 	delTestBot(bot, t, ch)
@@ -289,27 +283,17 @@ func TestIRCURLParserYoutube(t *testing.T) {
 		t.Fail()
 	}
 
-	var irc *IRC
-	for _, mod := range bot.modules {
-		if _, ok := mod.(*IRC); ok {
-			irc = mod.(*IRC)
-			break
-		}
-	}
-	if irc == nil {
-		t.Fail()
-	}
+	irc := testIRC(bot, t)
 
-	r, w := net.Pipe()
-	irc.conn = w
+	r := testConn(irc)
 
-	irc.onCommand("PRIVMSG", "foo", "#candice :https://www.youtube.com/watch?v=Pd12BmxP-08")
+	irc.onCommand(command("PRIVMSG", "foo", "#candice :https://www.youtube.com/watch?v=Pd12BmxP-08"))
 	readLog(r, t)
 
-	irc.onCommand("PRIVMSG", "foo", "#candice :https://youtube.com/watch?v=Pd12BmxP-08")
+	irc.onCommand(command("PRIVMSG", "foo", "#candice :https://youtube.com/watch?v=Pd12BmxP-08"))
 	readLog(r, t)
 
-	irc.onCommand("PRIVMSG", "foo", "#candice :https://youtu.be/QEllLECo4OM")
+	irc.onCommand(command("PRIVMSG", "foo", "#candice :https://youtu.be/QEllLECo4OM"))
 	readLog(r, t)
 
 	irc.conn = nil
@@ -0,0 +1,126 @@
+// Copyright 2016 Alex Fluter
+
+package bot
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultTLSExpiryWarn is the number of days before the server
+	// certificate expires to start warning
+	DefaultTLSExpiryWarn = 14
+)
+
+var (
+	ErrTLSCAFile      = errors.New("No certificates found in CA file")
+	ErrTLSFingerprint = errors.New("Server certificate does not match the pinned fingerprint")
+)
+
+// certFingerprint returns the hex encoded SHA-256 of the certificate.
+func certFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// normFingerprint accepts fingerprints with colons and in upper case.
+func normFingerprint(fp string) string {
+	fp = strings.Replace(fp, ":", "", -1)
+	return strings.ToLower(strings.TrimSpace(fp))
+}
+
+// tlsConfig builds the TLS configuration to connect to server.
+func (irc *IRC) tlsConfig(server *ServerConfig) (*tls.Config, error) {
+	var config *tls.Config
+	var fingerprint string
+
+	config = new(tls.Config)
+	config.ServerName = server.ServerName
+	if config.ServerName == "" {
+		config.ServerName = irc.config.TLSServerName
+	}
+	if config.ServerName == "" {
+		config.ServerName = server.Host
+	}
+
+	if irc.config.TLSCAFile != "" {
+		data, err := ioutil.ReadFile(irc.config.TLSCAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(data) {
+			return nil, ErrTLSCAFile
+		}
+	}
+
+	if irc.config.TLSInsecure {
+		irc.Logger.Println("TLS certificate verification is disabled")
+		config.InsecureSkipVerify = true
+	}
+
+	fingerprint = server.Fingerprint
+	if fingerprint == "" {
+		fingerprint = irc.config.TLSFingerprint
+	}
+	if fingerprint != "" {
+		// the pinned certificate is trusted instead of the CAs
+		fingerprint = normFingerprint(fingerprint)
+		config.InsecureSkipVerify = true
+		config.VerifyPeerCertificate = func(raw [][]byte, _ [][]*x509.Certificate) error {
+			if len(raw) == 0 {
+				return ErrTLSFingerprint
+			}
+			sum := sha256.Sum256(raw[0])
+			if hex.EncodeToString(sum[:]) != fingerprint {
+				return ErrTLSFingerprint
+			}
+			return nil
+		}
+	}
+
+	if certFile, keyFile := irc.config.GetTLSCert(); certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to load client certificate: %s", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// checkCerts logs the server certificates and warns if the server
+// certificate expires soon.
+func (irc *IRC) checkCerts(state tls.ConnectionState) {
+	var days int
+
+	irc.Logger.Printf("Version %X Cipher %X",
+		state.Version,
+		state.CipherSuite)
+	irc.Logger.Printf("receiving %d certificates",
+		len(state.PeerCertificates))
+	for i, cert := range state.PeerCertificates {
+		irc.Logger.Printf("certificate %d: %s", i, dumpCert(cert))
+	}
+	if len(state.PeerCertificates) == 0 {
+		return
+	}
+
+	days = irc.config.TLSExpiryWarn
+	if days <= 0 {
+		days = DefaultTLSExpiryWarn
+	}
+	cert := state.PeerCertificates[0]
+	if left := cert.NotAfter.Sub(time.Now()); left < time.Duration(days)*24*time.Hour {
+		irc.Logger.Printf("Warning: server certificate expires in %d days on %s",
+			int(left.Hours()/24), cert.NotAfter.Format(time.RFC3339))
+	}
+}
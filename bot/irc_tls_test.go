@@ -0,0 +1,98 @@
+package bot
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testCert(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "irc.example.com"},
+		DNSNames:     []string{"irc.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func tlsHandshake(cert tls.Certificate, config *tls.Config) error {
+	l, err := tls.Listen("tcp", "127.0.0.1:0",
+		&tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+	go func() {
+		if c, err := l.Accept(); err == nil {
+			c.(*tls.Conn).Handshake()
+			c.Close()
+		}
+	}()
+	c, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	return tls.Client(c, config).Handshake()
+}
+
+func TestTLSConfig(t *testing.T) {
+	cert := testCert(t)
+	leaf, _ := x509.ParseCertificate(cert.Certificate[0])
+	fp := certFingerprint(leaf)
+
+	irc := &IRC{config: &IRCConfig{}}
+	irc.Logger = NewLoggerFunc("")
+	server := &ServerConfig{Host: "irc.example.com", Port: 6697, Ssl: true}
+
+	config, err := irc.tlsConfig(server)
+	if err != nil || config.ServerName != "irc.example.com" {
+		t.Fatal(err, config.ServerName)
+	}
+	// self signed certificate is not trusted by default
+	if err = tlsHandshake(cert, config); err == nil {
+		t.Error("handshake should fail")
+	}
+
+	server.Fingerprint = strings.ToUpper(fp[:2]) + ":" + fp[2:]
+	config, _ = irc.tlsConfig(server)
+	if err = tlsHandshake(cert, config); err != nil {
+		t.Error(err)
+	}
+
+	server.Fingerprint = strings.Repeat("0", 64)
+	config, _ = irc.tlsConfig(server)
+	if err = tlsHandshake(cert, config); err == nil {
+		t.Error("handshake should fail with wrong fingerprint")
+	}
+
+	server.Fingerprint = ""
+	irc.config.TLSInsecure = true
+	irc.config.TLSServerName = "other.example.com"
+	config, _ = irc.tlsConfig(server)
+	if err = tlsHandshake(cert, config); err != nil || config.ServerName != "other.example.com" {
+		t.Error(err, config.ServerName)
+	}
+
+	if s := dumpCert(leaf); !strings.Contains(s, fp) {
+		t.Error(s)
+	}
+}
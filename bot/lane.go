@@ -0,0 +1,261 @@
+// Copyright 2016 Alex Fluter
+
+package bot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DefaultEventQueue = 100
+	// lanes without events for this long are closed
+	laneIdle = time.Minute
+)
+
+// laneBarrier holds the lanes sharing an event until all of them reach it.
+type laneBarrier struct {
+	pending int32
+	done    chan bool
+}
+
+type laneEvent struct {
+	event   *Event
+	barrier *laneBarrier
+}
+
+// eventLane handles the events of one key in order.
+type eventLane struct {
+	key      string
+	queue    chan *laneEvent
+	handled  uint64
+	dropped  uint64
+	maxDepth int
+}
+
+// eventLanes dispatches the events to a lane per network and channel,
+// the lanes run in parallel. Events are ordered within a channel and
+// with the events of its network, not across channels.
+type eventLanes struct {
+	sync.Mutex
+	bot     *Bot
+	lanes   map[string]*eventLane
+	size    int
+	stopped bool
+	// counters of the closed lanes
+	handled uint64
+	dropped uint64
+	quit    chan bool
+	wait    sync.WaitGroup
+}
+
+func newEventLanes(bot *Bot, size int) *eventLanes {
+	l := new(eventLanes)
+	l.bot = bot
+	l.size = size
+	l.lanes = make(map[string]*eventLane)
+	l.quit = make(chan bool)
+	return l
+}
+
+// eventTarget returns the network and the channel or nick of the event,
+// the target is empty for the events of the whole network.
+func eventTarget(event *Event) (irc *IRC, target string) {
+	switch data := event.data.(type) {
+	case *UserJoinData:
+		irc, target = data.irc, data.channel
+	case *UserPartData:
+		irc, target = data.irc, data.channel
+	case *UserKickData:
+		irc, target = data.irc, data.channel
+	case *ChannelMessageData:
+		irc, target = data.irc, data.channel
+	case *PrivateMessageData:
+		irc, target = data.irc, data.nick
+	case *MessageRequest:
+		irc, target = data.irc, data.target()
+	case *UserQuitData:
+		irc = data.irc
+	case *UserNickData:
+		irc = data.irc
+	case *PongData:
+		irc = data.irc
+	case *IRC:
+		irc = data
+	}
+	return
+}
+
+// eventKey returns the lane of the event, events of the same channel
+// or private message target share a lane, others go by network.
+func eventKey(event *Event) string {
+	irc, target := eventTarget(event)
+	if irc == nil {
+		return ""
+	}
+	if target == "" {
+		return irc.Name
+	}
+	return irc.Name + " " + irc.isupport.Fold(target)
+}
+
+// lane returns the lane of the key, started if there is none.
+func (l *eventLanes) lane(key string) *eventLane {
+	lane := l.lanes[key]
+	if lane == nil {
+		lane = &eventLane{key: key, queue: make(chan *laneEvent, l.size)}
+		l.lanes[key] = lane
+		l.wait.Add(1)
+		go l.run(lane)
+	}
+	return lane
+}
+
+// dispatch queues the event to its lane, it is dropped if the lane is full.
+// Events of the whole network, like NICK and QUIT, are queued to the lanes
+// of its channels too, so they are ordered with the channel events.
+func (l *eventLanes) dispatch(event *Event) {
+	var key string
+	var lanes []*eventLane
+	var item *laneEvent
+
+	key = eventKey(event)
+
+	l.Lock()
+	defer l.Unlock()
+	if l.stopped {
+		return
+	}
+	lanes = append(lanes, l.lane(key))
+	if irc, target := eventTarget(event); irc != nil && target == "" {
+		for k, lane := range l.lanes {
+			if strings.HasPrefix(k, key+" ") {
+				lanes = append(lanes, lane)
+			}
+		}
+	}
+	// all or none, a barrier missing from a lane never completes
+	for _, lane := range lanes {
+		if len(lane.queue) == cap(lane.queue) {
+			lane.dropped++
+			l.bot.Logger.Printf("Event lane %q is full, %s dropped", lane.key, event.evt)
+			return
+		}
+	}
+	item = &laneEvent{event: event}
+	if len(lanes) > 1 {
+		item.barrier = &laneBarrier{pending: int32(len(lanes)), done: make(chan bool)}
+	}
+	for _, lane := range lanes {
+		lane.queue <- item
+		if n := len(lane.queue); n > lane.maxDepth {
+			lane.maxDepth = n
+		}
+	}
+}
+
+// handle runs the handlers of the event, a barrier is run by the last lane
+// reaching it while the others wait, returns false if it was not run here.
+func (l *eventLanes) handle(item *laneEvent) bool {
+	b := item.barrier
+	if b == nil {
+		l.bot.handleEvent(item.event)
+		return true
+	}
+	if atomic.AddInt32(&b.pending, -1) == 0 {
+		l.bot.handleEvent(item.event)
+		close(b.done)
+		return true
+	}
+	select {
+	case <-b.done:
+	case <-l.quit:
+	}
+	return false
+}
+
+func (l *eventLanes) run(lane *eventLane) {
+	var idle *time.Timer
+
+	defer l.wait.Done()
+	idle = time.NewTimer(laneIdle)
+	defer idle.Stop()
+	for {
+		select {
+		case item := <-lane.queue:
+			handled := l.handle(item)
+			l.Lock()
+			if handled {
+				lane.handled++
+			}
+			l.Unlock()
+			if !idle.Stop() {
+				select {
+				case <-idle.C:
+				default:
+				}
+			}
+			idle.Reset(laneIdle)
+		case <-idle.C:
+			// events are queued under the lock, so the lane
+			// is known to be empty when it is removed
+			l.Lock()
+			if len(lane.queue) == 0 {
+				delete(l.lanes, lane.key)
+				l.handled += lane.handled
+				l.dropped += lane.dropped
+				l.Unlock()
+				return
+			}
+			l.Unlock()
+			idle.Reset(laneIdle)
+		case <-l.quit:
+			return
+		}
+	}
+}
+
+func (l *eventLanes) stop() {
+	l.Lock()
+	if l.stopped {
+		l.Unlock()
+		return
+	}
+	l.stopped = true
+	close(l.quit)
+	l.Unlock()
+	l.wait.Wait()
+}
+
+// Stats returns the event counters and the depth of the busiest lanes.
+func (l *eventLanes) Stats() string {
+	var handled, dropped uint64
+	var queued int
+	var lanes []*eventLane
+	var busy []string
+
+	l.Lock()
+	handled, dropped = l.handled, l.dropped
+	for _, lane := range l.lanes {
+		handled += lane.handled
+		dropped += lane.dropped
+		queued += len(lane.queue)
+		lanes = append(lanes, lane)
+	}
+	sort.Slice(lanes, func(i, j int) bool {
+		return len(lanes[i].queue) > len(lanes[j].queue)
+	})
+	for i := 0; i < len(lanes) && i < 5; i++ {
+		busy = append(busy, fmt.Sprintf("%q %d/%d max %d dropped %d",
+			lanes[i].key, len(lanes[i].queue), l.size,
+			lanes[i].maxDepth, lanes[i].dropped))
+	}
+	l.Unlock()
+	return fmt.Sprintf("%d %s, %d handled, %d queued, %d dropped [%s]",
+		len(lanes), sp("lane", "lanes", len(lanes)),
+		handled, queued, dropped, strings.Join(busy, ", "))
+}
@@ -0,0 +1,129 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventLanes(t *testing.T) {
+	var got = make(chan string, 10)
+	var block = make(chan bool)
+
+	bot := &Bot{bus: newEventBus(), crashes: newCrashes(), config: &BotConfig{}}
+	bot.Logger = NewTestLogger("lane")
+	bot.lanes = newEventLanes(bot, 2)
+
+	irc := &IRC{isupport: NewISupport()}
+	irc.Name = "net"
+	msg := func(channel, text string) *Event {
+		data := new(ChannelMessageData)
+		data.irc = irc
+		data.channel = channel
+		data.text = text
+		return NewEvent(ChannelMessage, data)
+	}
+	bot.Subscribe(OnChannelMessage(func(ctx *EventContext, data *ChannelMessageData) {
+		if data.text == "block" {
+			<-block
+		}
+		got <- data.channel + " " + data.text
+	}, Normal))
+
+	if key := eventKey(msg("#A", "x")); key != "net #a" {
+		t.Error("wrong key", key)
+	}
+
+	// #a is busy, the lane of #b is not held up by it
+	bot.AddEvent(msg("#a", "block"))
+	time.Sleep(10 * time.Millisecond)
+	bot.AddEvent(msg("#a", "1"))
+	bot.AddEvent(msg("#a", "2"))
+	bot.AddEvent(msg("#a", "3"))
+	bot.AddEvent(msg("#b", "1"))
+	select {
+	case s := <-got:
+		if s != "#b 1" {
+			t.Error("expected #b first, got", s)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("#b blocked by #a")
+	}
+
+	stats := bot.lanes.Stats()
+	if !strings.Contains(stats, "1 dropped") || !strings.Contains(stats, `"net #a" 2/2`) {
+		t.Error(stats)
+	}
+
+	close(block)
+	for _, want := range []string{"#a block", "#a 1", "#a 2"} {
+		select {
+		case s := <-got:
+			if s != want {
+				t.Errorf("expected %q, got %q", want, s)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for", want)
+		}
+	}
+	bot.lanes.stop()
+	t.Log(bot.lanes.Stats())
+}
+
+func TestEventLaneBarrier(t *testing.T) {
+	var got = make(chan string, 10)
+	var block = make(chan bool)
+
+	bot := &Bot{bus: newEventBus(), crashes: newCrashes(), config: &BotConfig{}}
+	bot.Logger = NewTestLogger("lane")
+	bot.lanes = newEventLanes(bot, 10)
+
+	irc := &IRC{isupport: NewISupport()}
+	irc.Name = "net"
+	msg := func(channel, text string) *Event {
+		data := new(ChannelMessageData)
+		data.irc = irc
+		data.channel = channel
+		data.text = text
+		return NewEvent(ChannelMessage, data)
+	}
+	bot.Subscribe(OnChannelMessage(func(ctx *EventContext, data *ChannelMessageData) {
+		if data.text == "block" {
+			<-block
+		}
+		got <- data.channel + " " + data.text
+	}, Normal))
+	bot.Subscribe(OnUserNick(func(ctx *EventContext, data *UserNickData) {
+		got <- "nick " + data.newNick
+	}, Normal))
+
+	bot.AddEvent(msg("#a", "block"))
+	bot.AddEvent(msg("#b", "before"))
+	if s := <-got; s != "#b before" {
+		t.Error(s)
+	}
+	// the nick change waits for #a, and #b waits for the nick change
+	bot.AddEvent(NewEvent(UserNick, &UserNickData{irc: irc, newNick: "foo"}))
+	bot.AddEvent(msg("#b", "after"))
+	select {
+	case s := <-got:
+		t.Error("not ordered:", s)
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(block)
+	for _, want := range []string{"#a block", "nick foo", "#b after"} {
+		select {
+		case s := <-got:
+			if s != want {
+				t.Errorf("expected %q, got %q", want, s)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for", want)
+		}
+	}
+	bot.lanes.stop()
+	// the barrier is counted once
+	if stats := bot.lanes.Stats(); !strings.Contains(stats, "4 handled") {
+		t.Error(stats)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"log"
 	"os"
 	"runtime"
+	"strings"
 )
 
 var NewLoggerFunc = NewLogger
@@ -36,6 +37,18 @@ func NewLogger(name string) *log.Logger {
 	return log.New(f, "", log.LstdFlags|log.Lshortfile)
 }
 
+// logWriter writes each line to the logger.
+type logWriter struct {
+	*log.Logger
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		w.Output(2, line)
+	}
+	return len(p), nil
+}
+
 func NewTestLogger(name string) *log.Logger {
 	return log.New(os.Stderr, name, log.LstdFlags|log.Lshortfile)
 }
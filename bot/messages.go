@@ -911,7 +911,7 @@ const (
 	ERR_SASLTOOLONG               // /* 905 ERR_SASLTOOLONG */	":%s 905 %s :SASL message too long",
 	ERR_SASLABORTED               // /* 906 ERR_SASLABORTED */	":%s 906 %s :SASL authentication aborted",
 	ERR_SASLALREADY               // /* 907 ERR_SASLALREADY */	":%s 907 %s :You have already completed SASL authentication",
-	_                             // /* 908 */	NULL,
+	RPL_SASLMECHS                 // /* 908 RPL_SASLMECHS */	":%s 908 %s %s :are available SASL mechanisms",
 	_                             // /* 909 */	NULL,
 	_                             // /* 910 */	NULL,
 	_                             // /* 911 */	NULL,
@@ -1914,7 +1914,7 @@ var Numerics [1000]string = [1000]string{
 	"ERR_SASLTOOLONG", // /* 905 ERR_SASLTOOLONG */	":%s 905 %s :SASL message too long",
 	"ERR_SASLABORTED", // /* 906 ERR_SASLABORTED */	":%s 906 %s :SASL authentication aborted",
 	"ERR_SASLALREADY", // /* 907 ERR_SASLALREADY */	":%s 907 %s :You have already completed SASL authentication",
-	"",
+	"RPL_SASLMECHS",   // /* 908 RPL_SASLMECHS */	":%s 908 %s %s :are available SASL mechanisms",
 	"",
 	"",
 	"",
@@ -3,7 +3,10 @@
 package bot
 
 import (
+	"errors"
+	"fmt"
 	"log"
+	"strings"
 	"sync"
 )
 
@@ -33,3 +36,161 @@ var (
 func RegisterInitModuleFunc(f func(*Bot) Module) {
 	initModuleFuncs = append(initModuleFuncs, f)
 }
+
+// Handler is an event handler with its priority, it is made by the
+// functions of the event types like OnChannelMessage so a handler of
+// the wrong type does not compile.
+type Handler struct {
+	evt  EventType
+	fn   interface{}
+	prio Priority
+}
+
+// Unloadable is optionally implemented by the addon modules, the commands and
+// handlers are registered by the bot after Start and removed before Stop,
+// so the module can be disabled and enabled while the bot is running.
+type Unloadable interface {
+	Commands() map[string]Command
+	Handlers() []Handler
+}
+
+var (
+	ErrModuleNotFound = errors.New("No such module")
+	ErrModuleEnabled  = errors.New("Module is already enabled")
+	ErrModuleDisabled = errors.New("Module is not enabled")
+)
+
+// moduleRegs is what the bot registered for a running addon module.
+type moduleRegs struct {
+	subs     []*Subscription
+	commands []string
+}
+
+// GetModule returns the addon module by name, case insensitive.
+func (bot *Bot) GetModule(name string) Module {
+	for _, mod := range bot.addons {
+		if strings.EqualFold(fmt.Sprint(mod), name) {
+			return mod
+		}
+	}
+	return nil
+}
+
+// isRunning checks if the addon module is started.
+func (bot *Bot) isRunning(mod Module) bool {
+	bot.modLock.Lock()
+	defer bot.modLock.Unlock()
+	_, ok := bot.running[mod]
+	return ok
+}
+
+// setEnabled remembers the state of the module in the config.
+func (bot *Bot) setEnabled(mod Module, enabled bool) {
+	bot.modLock.Lock()
+	defer bot.modLock.Unlock()
+	bot.config.SetModuleEnabled(fmt.Sprint(mod), enabled)
+}
+
+// startModule starts the addon module and registers what it owns.
+func (bot *Bot) startModule(mod Module) error {
+	var regs moduleRegs
+	var err error
+
+	if err = mod.Init(); err != nil {
+		return err
+	}
+	if err = mod.Start(); err != nil {
+		return err
+	}
+	if owner, ok := mod.(Unloadable); ok {
+		for _, h := range owner.Handlers() {
+			regs.subs = append(regs.subs,
+				bot.bus.subscribeOwned(h.evt, h.fn, h.prio, mod))
+		}
+		for name, cmd := range owner.Commands() {
+			regs.commands = append(regs.commands, name)
+			name, cmd := name, cmd
+			bot.foreachIRC(func(irc *IRC) {
+				irc.interpreter.registerCommand(name, cmd, mod)
+			})
+		}
+	}
+	mod.Run()
+
+	bot.modLock.Lock()
+	bot.running[mod] = &regs
+	bot.modLock.Unlock()
+	return nil
+}
+
+// stopModule removes what the module owns then stops it.
+func (bot *Bot) stopModule(mod Module) error {
+	bot.modLock.Lock()
+	regs := bot.running[mod]
+	delete(bot.running, mod)
+	bot.modLock.Unlock()
+
+	if regs != nil {
+		for _, sub := range regs.subs {
+			sub.Unsubscribe()
+		}
+		for _, name := range regs.commands {
+			bot.foreachIRC(func(irc *IRC) {
+				irc.interpreter.DelCommand(name)
+			})
+		}
+	}
+	return mod.Stop()
+}
+
+// EnableModule starts the module and marks it enabled in the config,
+// a module disabled after crashes is given another chance.
+func (bot *Bot) EnableModule(name string) error {
+	mod := bot.GetModule(name)
+	if mod == nil {
+		return ErrModuleNotFound
+	}
+	if bot.isRunning(mod) {
+		return ErrModuleEnabled
+	}
+	bot.crashes.reset(fmt.Sprint(mod))
+	if err := bot.startModule(mod); err != nil {
+		return err
+	}
+	bot.setEnabled(mod, true)
+	bot.Logger.Printf("Module %s enabled", mod)
+	return nil
+}
+
+// DisableModule stops the module and marks it disabled in the config.
+func (bot *Bot) DisableModule(name string) error {
+	mod := bot.GetModule(name)
+	if mod == nil {
+		return ErrModuleNotFound
+	}
+	if !bot.isRunning(mod) {
+		return ErrModuleDisabled
+	}
+	bot.setEnabled(mod, false)
+	if err := bot.stopModule(mod); err != nil {
+		return err
+	}
+	bot.Logger.Printf("Module %s disabled", mod)
+	return nil
+}
+
+// RestartModule stops and starts the running module.
+func (bot *Bot) RestartModule(name string) error {
+	mod := bot.GetModule(name)
+	if mod == nil {
+		return ErrModuleNotFound
+	}
+	if !bot.isRunning(mod) {
+		return ErrModuleDisabled
+	}
+	if err := bot.stopModule(mod); err != nil {
+		return err
+	}
+	bot.crashes.reset(fmt.Sprint(mod))
+	return bot.startModule(mod)
+}
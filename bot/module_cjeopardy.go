@@ -11,6 +11,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -32,6 +33,11 @@ type Cjeopardy struct {
 	modnick string // nickname of the moderator
 	prefix  string // prefix of the question
 	key     string // keyword to prepend to the answer
+
+	// lock guards db and the answers waiting to be sent,
+	// the handlers may be running while the module is stopped
+	lock    sync.Mutex
+	answers map[*time.Timer]bool
 }
 
 func init() {
@@ -71,14 +77,15 @@ func (cj *Cjeopardy) Start() error {
 		dbReader = f
 	}
 
+	// emptied by Stop
+	cj.lock.Lock()
+	defer cj.lock.Unlock()
+	cj.db = make(map[int]string)
+	cj.answers = make(map[*time.Timer]bool)
 	if err := cj.load(dbReader); err != nil {
 		return err
 	}
 
-	cj.bot.RegisterEventHandler(ChannelMessage, cj.handleMessage)
-	cj.bot.foreachIRC(func(irc *IRC) {
-		irc.interpreter.RegisterCommand("cjeopardy", cj.handleCommand)
-	})
 	cj.State = Running
 	return nil
 }
@@ -128,9 +135,15 @@ func (cj *Cjeopardy) load(r io.ReadCloser) error {
 }
 
 func (cj *Cjeopardy) Stop() error {
+	cj.lock.Lock()
+	for timer := range cj.answers {
+		timer.Stop()
+	}
+	cj.answers = nil
+	cj.db = nil
+	cj.lock.Unlock()
 	cj.Logger.Println("Cjeopardy stopped")
 	cj.State = Stopped
-	cj.db = nil
 	return nil
 }
 
@@ -145,14 +158,17 @@ func (cj *Cjeopardy) Status() string {
 func (cj *Cjeopardy) Run() {
 }
 
-func (cj *Cjeopardy) handleMessage(data interface{}) {
+func (cj *Cjeopardy) Commands() map[string]Command {
+	return map[string]Command{"cjeopardy": cj.handleCommand}
+}
 
-	msg, ok := data.(*ChannelMessageData)
-	if !ok {
-		cj.Logger.Println("corrupted channel message data")
-		return
-	}
-	if msg.nick != cj.modnick {
+func (cj *Cjeopardy) Handlers() []Handler {
+	return []Handler{OnChannelMessage(cj.handleMessage, Normal)}
+}
+
+func (cj *Cjeopardy) handleMessage(ctx *EventContext, msg *ChannelMessageData) {
+	// the moderator may be set per channel
+	if msg.nick != msg.irc.GetSetting(msg.channel, cj.Name, "moderator", cj.modnick) {
 		return
 	}
 	if !strings.HasPrefix(msg.text, cj.prefix) {
@@ -165,10 +181,26 @@ func (cj *Cjeopardy) handleMessage(data interface{}) {
 		return
 	}
 	if id, err := strconv.ParseInt(question[:pos], 10, 0); err == nil {
-		time.AfterFunc(time.Duration(rand.Intn(15))*time.Second, func() {
-			msg.irc.Privmsg(msg.channel, fmt.Sprintf("%s %s", cj.key, cj.db[int(id)]))
+		var timer *time.Timer
+
+		cj.lock.Lock()
+		defer cj.lock.Unlock()
+		if cj.db == nil {
+			// stopped
+			return
+		}
+		answer := fmt.Sprintf("%s %s", cj.key, cj.db[int(id)])
+		timer = time.AfterFunc(time.Duration(rand.Intn(15))*time.Second, func() {
+			cj.lock.Lock()
+			pending := cj.answers[timer]
+			delete(cj.answers, timer)
+			cj.lock.Unlock()
+			if pending {
+				msg.irc.Privmsg(msg.channel, answer)
+			}
 		})
-		//cj.Logger.Println(msg.channel, ":", fmt.Sprintf("%s %s", cj.key, cj.db[int(id)]))
+		cj.answers[timer] = true
+		//cj.Logger.Println(msg.channel, ":", answer)
 	}
 }
 
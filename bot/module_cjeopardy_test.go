@@ -1,7 +1,6 @@
 package bot
 
 import (
-	"net"
 	"testing"
 )
 
@@ -9,30 +8,20 @@ func TestCjeopardy(t *testing.T) {
 	ch := make(chan bool)
 	bot := newTestBot(ch)
 
-	var irc *IRC
-	for _, mod := range bot.modules {
-		if _, ok := mod.(*IRC); ok {
-			irc = mod.(*IRC)
-			break
-		}
-	}
-	if irc == nil {
-		t.Fail()
-	}
-	r, w := net.Pipe()
-	irc.conn = w
+	irc := testIRC(bot, t)
+	r := testConn(irc)
 
 	//	bot.AddEvent(NewEvent(ChannelMessage, nil))
 
 	s1 := "8) [3.6 Terms, definitions, and symbols] A byte is composed of a contiguous sequence of bits, the number of which is this.|implementation-defined{Bet you thought it was 8!}"
 	s2 := "9) [3.6 Terms, definitions, and symbols] The least significant bit is called this.|low-order bit"
-	irc.onCommand("PRIVMSG", "foo", "#candice :"+s1)
+	irc.onCommand(command("PRIVMSG", "foo", "#candice :"+s1))
 
-	irc.onCommand("PRIVMSG", "foo", "#candice :"+cjeopardy_prefix+s1)
-	irc.onCommand("PRIVMSG", "foo", "#candice :"+cjeopardy_prefix+s2)
+	irc.onCommand(command("PRIVMSG", "foo", "#candice :"+cjeopardy_prefix+s1))
+	irc.onCommand(command("PRIVMSG", "foo", "#candice :"+cjeopardy_prefix+s2))
 
-	irc.onCommand("PRIVMSG", cjeopardy_modnick, "#candice :"+cjeopardy_prefix+s1)
-	irc.onCommand("PRIVMSG", cjeopardy_modnick, "#candice :"+cjeopardy_prefix+s2)
+	irc.onCommand(command("PRIVMSG", cjeopardy_modnick, "#candice :"+cjeopardy_prefix+s1))
+	irc.onCommand(command("PRIVMSG", cjeopardy_modnick, "#candice :"+cjeopardy_prefix+s2))
 
 	buf := make([]byte, 1024)
 	n, _ := r.Read(buf)
@@ -43,3 +32,31 @@ func TestCjeopardy(t *testing.T) {
 	irc.conn = nil
 	delTestBot(bot, t, ch)
 }
+
+func TestCjeopardyStop(t *testing.T) {
+	ch := make(chan bool)
+	bot := newTestBot(ch)
+	irc := testIRC(bot, t)
+
+	cj := NewCjeopardy(bot).(*Cjeopardy)
+	cj.Start()
+	cj.db[1] = "answer"
+	msg := new(ChannelMessageData)
+	msg.irc = irc
+	msg.channel = "#candice"
+	msg.nick = cjeopardy_modnick
+	msg.text = cjeopardy_prefix + "1) question"
+	cj.handleMessage(nil, msg)
+	if len(cj.answers) != 1 {
+		t.Error(cj.answers)
+	}
+
+	// the pending answer is dropped, later messages are ignored
+	cj.Stop()
+	cj.handleMessage(nil, msg)
+	if len(cj.answers) != 0 || cj.db != nil {
+		t.Error(cj.answers)
+	}
+
+	delTestBot(bot, t, ch)
+}
@@ -0,0 +1,249 @@
+// Copyright 2016 Alex Fluter
+
+package bot
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// the control protocol is line based, each command line is answered by
+// its output lines prefixed with controlOutput, then a status line
+const (
+	controlOutput = "> "
+	controlOK     = "OK"
+	controlErr    = "ERR "
+)
+
+var ErrControlClosed = errors.New("Control connection closed")
+
+// Control serves the engine commands on a unix socket.
+type Control struct {
+	BaseModule
+	bot      *Bot
+	path     string
+	listener net.Listener
+	clients  map[net.Conn]bool
+	lock     sync.Mutex
+}
+
+func init() {
+	RegisterInitModuleFunc(NewControl)
+}
+
+func NewControl(bot *Bot) Module {
+	if bot.config.ControlSocket == "" {
+		return nil
+	}
+	c := new(Control)
+	c.bot = bot
+	c.Name = "Control"
+	c.path = bot.config.ControlSocket
+	c.Logger = bot.Logger
+	c.clients = make(map[net.Conn]bool)
+	return c
+}
+
+func (c *Control) Init() error {
+	c.Logger.Println("Initializing Control")
+	c.State = Initialized
+	return nil
+}
+
+func (c *Control) Start() error {
+	var err error
+	var dir, tmp string
+
+	c.Logger.Printf("Starting Control on %s", c.path)
+	// a socket left by a previous run refuses connections
+	if conn, err := net.Dial("unix", c.path); err == nil {
+		conn.Close()
+		return fmt.Errorf("Control socket %s is in use", c.path)
+	}
+	os.Remove(c.path)
+
+	// the socket is created in a private directory and moved in place
+	// when only the owner can connect to it
+	dir, err = ioutil.TempDir(filepath.Dir(c.path), ".control")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	tmp = filepath.Join(dir, "ctl.sock")
+	c.listener, err = net.Listen("unix", tmp)
+	if err != nil {
+		return err
+	}
+	// removed by Stop under its final name
+	c.listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	if err = os.Chmod(tmp, 0600); err == nil {
+		err = os.Rename(tmp, c.path)
+	}
+	if err != nil {
+		c.listener.Close()
+		return err
+	}
+	c.State = Running
+	return nil
+}
+
+func (c *Control) Stop() error {
+	c.listener.Close()
+	os.Remove(c.path)
+	c.lock.Lock()
+	for conn := range c.clients {
+		conn.Close()
+	}
+	c.lock.Unlock()
+	c.wait.Wait()
+	c.Logger.Println("Control stopped")
+	c.State = Stopped
+	return nil
+}
+
+func (c *Control) String() string {
+	return c.Name
+}
+
+func (c *Control) Status() string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return fmt.Sprintf("%s, %s, %d %s", c.State, c.path,
+		len(c.clients), sp("client", "clients", len(c.clients)))
+}
+
+func (c *Control) Run() {
+	c.wait.Add(1)
+	go c.acceptLoop()
+}
+
+func (c *Control) acceptLoop() {
+	defer c.wait.Done()
+	for {
+		conn, err := c.listener.Accept()
+		if err != nil {
+			c.Logger.Printf("Control accept: %s", err)
+			return
+		}
+		c.lock.Lock()
+		c.clients[conn] = true
+		c.lock.Unlock()
+		c.wait.Add(1)
+		go c.serve(conn)
+	}
+}
+
+// controlWriter prefixes each line of the command output.
+type controlWriter struct {
+	w   io.Writer
+	buf []byte
+}
+
+func (cw *controlWriter) Write(p []byte) (int, error) {
+	cw.buf = append(cw.buf, p...)
+	for {
+		i := bytes.IndexByte(cw.buf, '\n')
+		if i == -1 {
+			break
+		}
+		if _, err := fmt.Fprintf(cw.w, "%s%s\n", controlOutput, cw.buf[:i]); err != nil {
+			return 0, err
+		}
+		cw.buf = cw.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+func (cw *controlWriter) flush() error {
+	if len(cw.buf) == 0 {
+		return nil
+	}
+	_, err := cw.Write([]byte("\n"))
+	return err
+}
+
+func (c *Control) serve(conn net.Conn) {
+	var scanner *bufio.Scanner
+	var line string
+	var trigger string
+
+	defer func() {
+		c.lock.Lock()
+		delete(c.clients, conn)
+		c.lock.Unlock()
+		conn.Close()
+		c.wait.Done()
+	}()
+
+	trigger = string(c.bot.config.GetTrigger())
+	scanner = bufio.NewScanner(conn)
+	for scanner.Scan() {
+		line = strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		c.Logger.Println("control input:", line)
+		// the trigger is optional
+		line = strings.TrimPrefix(line, trigger)
+
+		cw := &controlWriter{w: conn}
+		err := c.bot.engine.Execute(cw, nil, line)
+		cw.flush()
+		if err != nil {
+			fmt.Fprintf(conn, "%s%s\n", controlErr, err)
+		} else {
+			fmt.Fprintf(conn, "%s\n", controlOK)
+		}
+	}
+}
+
+// RunControl sends each line of in to the control socket at path
+// and copies the output to out, stops at the first failed command.
+func RunControl(path string, in io.Reader, out io.Writer) error {
+	var conn net.Conn
+	var err error
+
+	conn, err = net.Dial("unix", path)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	input := bufio.NewScanner(in)
+	reply := bufio.NewScanner(conn)
+	for input.Scan() {
+		line := strings.TrimSpace(input.Text())
+		if line == "" {
+			continue
+		}
+		if _, err = fmt.Fprintf(conn, "%s\n", line); err != nil {
+			return err
+		}
+		for {
+			if !reply.Scan() {
+				if err = reply.Err(); err != nil {
+					return err
+				}
+				return ErrControlClosed
+			}
+			s := reply.Text()
+			if strings.HasPrefix(s, controlOutput) {
+				fmt.Fprintln(out, s[len(controlOutput):])
+			} else if s == controlOK {
+				break
+			} else if strings.HasPrefix(s, controlErr) {
+				return errors.New(s[len(controlErr):])
+			}
+		}
+	}
+	return input.Err()
+}
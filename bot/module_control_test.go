@@ -0,0 +1,64 @@
+package bot
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestControl(t *testing.T) {
+	ch := make(chan bool)
+	bot := newTestBot(ch)
+
+	dir, err := ioutil.TempDir("", "subhuti")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	bot.config.ControlSocket = dir + "/ctl.sock"
+	c := NewControl(bot).(*Control)
+	c.Init()
+	if err = c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	c.Run()
+	if fi, err := os.Stat(bot.config.ControlSocket); err != nil || fi.Mode().Perm() != 0600 {
+		t.Error(fi, err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var out bytes.Buffer
+			err := RunControl(bot.config.ControlSocket, strings.NewReader("status\n/status\n"), &out)
+			if err != nil {
+				t.Error(err)
+			}
+			if strings.Count(out.String(), "======== Status: ========") != 2 {
+				t.Error(out.String())
+			}
+		}()
+	}
+	wg.Wait()
+
+	var out bytes.Buffer
+	err = RunControl(bot.config.ControlSocket, strings.NewReader("msg\nstatus\n"), &out)
+	if err == nil || err.Error() != ErrInsufficientArgs.Error() || out.Len() != 0 {
+		t.Error(err, out.String())
+	}
+	err = RunControl(bot.config.ControlSocket, strings.NewReader("foo\n"), &out)
+	if err == nil || !strings.HasPrefix(err.Error(), ErrUnknownCommand.Error()) {
+		t.Error(err)
+	}
+
+	c.Stop()
+	if _, err := os.Stat(bot.config.ControlSocket); !os.IsNotExist(err) {
+		t.Error("socket not removed", err)
+	}
+	delTestBot(bot, t, ch)
+}
@@ -40,17 +40,10 @@ func (f *FactoidProcessor) Init() error {
 
 func (f *FactoidProcessor) Start() error {
 	f.Logger.Println("Starting FactoidProcessor")
-	f.bot.foreachIRC(func(irc *IRC) {
-		irc.interpreter.RegisterCommand("factadd", f.factadd)
-		irc.interpreter.RegisterCommand("factrem", f.factrem)
-		irc.interpreter.RegisterCommand("factchange", f.factchange)
-		irc.interpreter.RegisterCommand("factfind", f.factfind)
-		irc.interpreter.RegisterCommand("factinfo", f.factinfo)
-		irc.interpreter.RegisterCommand("factshow", f.factshow)
-		irc.interpreter.RegisterCommand("factset", f.factset)
-		irc.interpreter.RegisterCommand("fact", f.factcall)
-	})
-	f.bot.RegisterEventHandler(MessageParseEvent, f.handleMessage)
+	// changing others' factoids is up to the channel operators
+	f.bot.perms.SetDefault("factrem", ChanOp)
+	f.bot.perms.SetDefault("factchange", ChanOp)
+	f.bot.perms.SetDefault("factset", ChanOp)
 	f.State = Running
 	//	f.factoids.Dump(os.Stderr)
 	return nil
@@ -74,6 +67,23 @@ func (f *FactoidProcessor) Status() string {
 func (f *FactoidProcessor) Run() {
 }
 
+func (f *FactoidProcessor) Commands() map[string]Command {
+	return map[string]Command{
+		"factadd":    f.factadd,
+		"factrem":    f.factrem,
+		"factchange": f.factchange,
+		"factfind":   f.factfind,
+		"factinfo":   f.factinfo,
+		"factshow":   f.factshow,
+		"factset":    f.factset,
+		"fact":       f.factcall,
+	}
+}
+
+func (f *FactoidProcessor) Handlers() []Handler {
+	return []Handler{OnMessageParse(f.handleMessage, Normal)}
+}
+
 // factadd <channel> <keyword> <factoid...>
 func (f *FactoidProcessor) factadd(req *MessageRequest, args string) (string, error) {
 	var channel, keyword, desc string
@@ -346,12 +356,7 @@ func (f *FactoidProcessor) factcall(req *MessageRequest, args string) (string, e
 	return factoid.Desc, nil
 }
 
-func (f *FactoidProcessor) handleMessage(data interface{}) {
-	req, ok := data.(*MessageRequest)
-	if !ok {
-		return
-	}
-
+func (f *FactoidProcessor) handleMessage(ctx *EventContext, req *MessageRequest) {
 	if req.keyword == "" {
 		return
 	}
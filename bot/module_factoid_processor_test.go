@@ -1,7 +1,6 @@
 package bot
 
 import (
-	"net"
 	"testing"
 )
 
@@ -9,77 +8,67 @@ func TestFactoidProcessor(t *testing.T) {
 	ch := make(chan bool)
 	bot := newTestBot(ch)
 
-	var irc *IRC
-	for _, mod := range bot.modules {
-		if _, ok := mod.(*IRC); ok {
-			irc = mod.(*IRC)
-			break
-		}
-	}
-	if irc == nil {
-		t.Fail()
-	}
-	r, w := net.Pipe()
-	irc.conn = w
+	irc := testIRC(bot, t)
+	r := testConn(irc)
 
-	irc.onCommand("PRIVMSG", "foo", "#candice :"+G+": factadd global hi hello")
+	irc.onCommand(command("PRIVMSG", "foo", "#candice :"+G+": factadd global hi hello"))
 	readLog(r, t)
 
-	irc.onCommand("PRIVMSG", "foo", "#candice :"+G+": factadd global int16 16bits")
+	irc.onCommand(command("PRIVMSG", "foo", "#candice :"+G+": factadd global int16 16bits"))
 	readLog(r, t)
 
-	irc.onCommand("PRIVMSG", "foo", "#candice :"+G+": factadd #c NULL Null is null pointer")
+	irc.onCommand(command("PRIVMSG", "foo", "#candice :"+G+": factadd #c NULL Null is null pointer"))
 	readLog(r, t)
 
-	irc.onCommand("PRIVMSG", "foo", "#candice :"+G+": factadd #c int Integer")
+	irc.onCommand(command("PRIVMSG", "foo", "#candice :"+G+": factadd #c int Integer"))
 	readLog(r, t)
 
-	irc.onCommand("PRIVMSG", "foo", "#candice :"+G+": factadd #c int32_t 32bits integer")
+	irc.onCommand(command("PRIVMSG", "foo", "#candice :"+G+": factadd #c int32_t 32bits integer"))
 	readLog(r, t)
 
-	irc.onCommand("PRIVMSG", "foo", "#candice :"+G+": factadd #c int64_t 64bits int")
+	irc.onCommand(command("PRIVMSG", "foo", "#candice :"+G+": factadd #c int64_t 64bits int"))
 	readLog(r, t)
 
-	irc.onCommand("PRIVMSG", "foo", "#candice :"+G+": factadd #c malloc malloc")
+	irc.onCommand(command("PRIVMSG", "foo", "#candice :"+G+": factadd #c malloc malloc"))
 	readLog(r, t)
 
-	irc.onCommand("PRIVMSG", "foo", "#candice :"+G+": factfind -channel foo -by bar hi")
+	irc.onCommand(command("PRIVMSG", "foo", "#candice :"+G+": factfind -channel foo -by bar hi"))
 	readLog(r, t)
 
-	irc.onCommand("PRIVMSG", "foo", "#candice :"+G+": factfind -channel #c int")
+	irc.onCommand(command("PRIVMSG", "foo", "#candice :"+G+": factfind -channel #c int"))
 	readLog(r, t)
 
-	irc.onCommand("PRIVMSG", "foo", "#candice :"+G+": factfind int")
+	irc.onCommand(command("PRIVMSG", "foo", "#candice :"+G+": factfind int"))
 	readLog(r, t)
 
-	irc.onCommand("PRIVMSG", "foo", "#candice :"+G+": factinfo int16")
+	irc.onCommand(command("PRIVMSG", "foo", "#candice :"+G+": factinfo int16"))
 	readLog(r, t)
 
-	irc.onCommand("PRIVMSG", "foo", "#candice :"+G+": factinfo #c int")
+	irc.onCommand(command("PRIVMSG", "foo", "#candice :"+G+": factinfo #c int"))
 	readLog(r, t)
 
-	irc.onCommand("PRIVMSG", "foo", "#candice :"+G+": factshow #c int32_t")
+	irc.onCommand(command("PRIVMSG", "foo", "#candice :"+G+": factshow #c int32_t"))
 	readLog(r, t)
 
-	irc.onCommand("PRIVMSG", "foo", "#candice :"+G+": fact #c int32_t")
+	irc.onCommand(command("PRIVMSG", "foo", "#candice :"+G+": fact #c int32_t"))
 	readLog(r, t)
 
-	irc.onCommand("PRIVMSG", "foo", "#candice :"+G+": fact int32_t")
+	irc.onCommand(command("PRIVMSG", "foo", "#candice :"+G+": fact int32_t"))
 	readLog(r, t)
 
-	irc.onCommand("PRIVMSG", "foo", "#c :"+G+": fact int32_t")
+	irc.onCommand(command("PRIVMSG", "foo", "#c :"+G+": fact int32_t"))
 	readLog(r, t)
 
-	irc.onCommand("PRIVMSG", "foo", "#c :"+G+": fact int16")
+	irc.onCommand(command("PRIVMSG", "foo", "#c :"+G+": fact int16"))
 	readLog(r, t)
 
-	irc.onCommand("PRIVMSG", "foo", "#c :"+G+": int32_t")
+	irc.onCommand(command("PRIVMSG", "foo", "#c :"+G+": int32_t"))
 	readLog(r, t)
 
-	irc.onCommand("PRIVMSG", "foo", "#c :?int32_t")
+	irc.onCommand(command("PRIVMSG", "foo", "#c :?int32_t"))
 	readLog(r, t)
 
-	irc.onCommand("PRIVMSG", "foo", "foo :hi")
+	irc.onCommand(command("PRIVMSG", "foo", "foo :hi"))
 	readLog(r, t)
 
 	irc.conn = nil
@@ -37,10 +37,6 @@ func (lc *LagChecker) Init() error {
 
 func (lc *LagChecker) Start() error {
 	lc.Logger.Println("Starting LagChecker")
-	lc.bot.RegisterEventHandler(Pong, lc.handlePong)
-	lc.bot.foreachIRC(func(irc *IRC) {
-		irc.interpreter.RegisterCommand("lagcheck", lc.run)
-	})
 	lc.State = Running
 	return nil
 }
@@ -62,13 +58,15 @@ func (lc *LagChecker) Status() string {
 func (lc *LagChecker) Run() {
 }
 
-func (lc *LagChecker) handlePong(data interface{}) {
+func (lc *LagChecker) Commands() map[string]Command {
+	return map[string]Command{"lagcheck": lc.run}
+}
 
-	pong, ok := data.(*PongData)
-	if !ok {
-		lc.Logger.Println("corrupted pong data")
-		return
-	}
+func (lc *LagChecker) Handlers() []Handler {
+	return []Handler{OnPong(lc.handlePong, Normal)}
+}
+
+func (lc *LagChecker) handlePong(ctx *EventContext, pong *PongData) {
 	if strings.HasPrefix(pong.origin, lagCheckMarker[1:]) {
 		now := time.Now().UnixNano()
 		then, err := strconv.ParseInt(pong.origin[len(lagCheckMarker):], 10, 64)
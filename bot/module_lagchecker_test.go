@@ -1,7 +1,6 @@
 package bot
 
 import (
-	"net"
 	"testing"
 )
 
@@ -9,24 +8,14 @@ func TestLagChecker(t *testing.T) {
 	ch := make(chan bool)
 	bot := newTestBot(ch)
 
-	var irc *IRC
-	for _, mod := range bot.modules {
-		if _, ok := mod.(*IRC); ok {
-			irc = mod.(*IRC)
-			break
-		}
-	}
-	if irc == nil {
-		t.Fail()
-	}
-	r, w := net.Pipe()
-	irc.conn = w
+	irc := testIRC(bot, t)
+	r := testConn(irc)
 
 	bot.AddEvent(NewEvent(Pong, nil))
 
-	irc.onCommand("PRIVMSG", "foo", "#candice :lagcheck")
+	irc.onCommand(command("PRIVMSG", "foo", "#candice :lagcheck"))
 
-	irc.onCommand("PRIVMSG", "foo", "#candice :"+G+": lagcheck")
+	irc.onCommand(command("PRIVMSG", "foo", "#candice :"+G+": lagcheck"))
 
 	buf := make([]byte, 1024)
 	n, _ := r.Read(buf)
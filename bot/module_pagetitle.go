@@ -63,7 +63,6 @@ func (t *Pagetitle) Init() error {
 
 func (t *Pagetitle) Start() error {
 	t.Logger.Println("Starting Pagetitle")
-	t.bot.RegisterEventHandlerPrio(MessageParseEvent, t.parseMessage, Low)
 	t.State = Running
 	return nil
 }
@@ -85,12 +84,15 @@ func (t *Pagetitle) Status() string {
 func (t *Pagetitle) Run() {
 }
 
-func (t *Pagetitle) parseMessage(data interface{}) {
-	req, ok := data.(*MessageRequest)
-	if !ok {
-		return
-	}
+func (t *Pagetitle) Commands() map[string]Command {
+	return nil
+}
+
+func (t *Pagetitle) Handlers() []Handler {
+	return []Handler{OnMessageParse(t.parseMessage, Low)}
+}
 
+func (t *Pagetitle) parseMessage(ctx *EventContext, req *MessageRequest) {
 	if req.neturl == nil {
 		return
 	}
@@ -43,7 +43,6 @@ func (cp *CodePasteChecker) Start() error {
 			cp.cs = nil
 		} else {
 			cp.cs = cs
-			cp.bot.RegisterEventHandler(MessageParseEvent, cp.handleMessage)
 		}
 	}
 	cp.State = Running
@@ -70,12 +69,19 @@ func (cp *CodePasteChecker) Status() string {
 func (cp *CodePasteChecker) Run() {
 }
 
-func (cp *CodePasteChecker) handleMessage(data interface{}) {
-	req, ok := data.(*MessageRequest)
-	if !ok {
-		return
+func (cp *CodePasteChecker) Commands() map[string]Command {
+	return nil
+}
+
+func (cp *CodePasteChecker) Handlers() []Handler {
+	if cp.cs == nil {
+		return nil
 	}
+	// before Pagetitle, which is skipped for the pastes
+	return []Handler{OnMessageParse(cp.handleMessage, Low+1)}
+}
 
+func (cp *CodePasteChecker) handleMessage(ctx *EventContext, req *MessageRequest) {
 	if req.neturl == nil {
 		return
 	}
@@ -88,7 +94,9 @@ func (cp *CodePasteChecker) handleMessage(data interface{}) {
 		return
 	}
 
-	lang := req.irc.config.ChannelLang(req.channel)
+	// the settings take precedence over the channel config
+	lang := req.irc.GetSetting(req.channel, cp.Name, "lang",
+		req.irc.config.ChannelLang(req.channel))
 	if lang == "" {
 		cp.Logger.Println("No language defined for channel", req.channel)
 		return
@@ -106,11 +114,12 @@ func (cp *CodePasteChecker) handleMessage(data interface{}) {
 		if req.neturl.Host == "sprunge.us" {
 			return
 		}
-		if req.irc.config.ChannelRepaste(req.channel) {
+		if req.irc.GetSettingBool(req.channel, cp.Name, "repaste",
+			req.irc.config.ChannelRepaste(req.channel)) {
 			req.irc.sendReply(res, req)
 		}
 	}
-	req.cleanURL()
+	ctx.Stop()
 }
 
 func (cp *CodePasteChecker) processCode(code string, lang string) (string, bool) {
@@ -0,0 +1,93 @@
+package bot
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+type testModule struct {
+	BaseModule
+	starts int
+	events int
+}
+
+func (m *testModule) Init() error    { return nil }
+func (m *testModule) Start() error   { m.starts++; return nil }
+func (m *testModule) Stop() error    { return nil }
+func (m *testModule) Status() string { return "ok" }
+func (m *testModule) Run()           {}
+func (m *testModule) String() string { return "TestModule" }
+
+func (m *testModule) Commands() map[string]Command {
+	return map[string]Command{"ping": m.ping}
+}
+
+func (m *testModule) Handlers() []Handler {
+	return []Handler{OnEvent(254, m.handle, Normal)}
+}
+
+func (m *testModule) ping(req *MessageRequest, args string) (string, error) {
+	return "pong", nil
+}
+
+func (m *testModule) handle(ctx *EventContext, data interface{}) {
+	m.events++
+}
+
+func TestModules(t *testing.T) {
+	var buf bytes.Buffer
+
+	ch := make(chan bool)
+	bot := newTestBot(ch)
+	irc := testIRC(bot, t)
+
+	mod := new(testModule)
+	bot.addons = append(bot.addons, mod)
+	bot.modules = append(bot.modules, mod)
+
+	if err := bot.engine.Execute(&buf, nil, "enable testmodule"); err != nil {
+		t.Fatal(err)
+	}
+	if mod.starts != 1 || irc.interpreter.GetCommand("ping") == nil {
+		t.Error("module not started")
+	}
+	bot.handleEvent(NewEvent(254, "x"))
+	if mod.events != 1 {
+		t.Error("handler not registered")
+	}
+	if err := bot.EnableModule("TestModule"); err != ErrModuleEnabled {
+		t.Error(err)
+	}
+
+	bot.engine.Execute(&buf, nil, "modules")
+	if !strings.Contains(buf.String(), "TestModule enabled, ok") {
+		t.Error(buf.String())
+	}
+
+	if err := bot.engine.Execute(&buf, nil, "restart TestModule"); err != nil {
+		t.Error(err)
+	}
+	if mod.starts != 2 || len(bot.bus.subscribers(254)) != 1 {
+		t.Error("module not restarted")
+	}
+
+	if err := bot.engine.Execute(&buf, nil, "disable TestModule"); err != nil {
+		t.Error(err)
+	}
+	bot.handleEvent(NewEvent(254, "x"))
+	if mod.events != 1 || irc.interpreter.GetCommand("ping") != nil {
+		t.Error("module not unloaded")
+	}
+	if bot.config.ModuleEnabled("TestModule") {
+		t.Error("disabled state not in config")
+	}
+	if err := bot.RestartModule("TestModule"); err != ErrModuleDisabled {
+		t.Error(err)
+	}
+	if err := bot.DisableModule("nothing"); err != ErrModuleNotFound {
+		t.Error(err)
+	}
+
+	delTestBot(bot, t, ch)
+}
@@ -55,9 +55,6 @@ func (yt *Youtube) Init() error {
 
 func (yt *Youtube) Start() error {
 	yt.Logger.Println("Starting Youtube")
-	if yt.key != nil {
-		yt.bot.RegisterEventHandler(MessageParseEvent, yt.parseMessage)
-	}
 	yt.State = Running
 	return nil
 }
@@ -79,12 +76,19 @@ func (yt *Youtube) Status() string {
 func (yt *Youtube) Run() {
 }
 
-func (yt *Youtube) parseMessage(data interface{}) {
-	req, ok := data.(*MessageRequest)
-	if !ok {
-		return
+func (yt *Youtube) Commands() map[string]Command {
+	return nil
+}
+
+func (yt *Youtube) Handlers() []Handler {
+	if yt.key == nil {
+		return nil
 	}
+	// before Pagetitle, which is skipped for the videos
+	return []Handler{OnMessageParse(yt.parseMessage, Low+1)}
+}
 
+func (yt *Youtube) parseMessage(ctx *EventContext, req *MessageRequest) {
 	if req.neturl == nil {
 		return
 	}
@@ -154,7 +158,7 @@ func (yt *Youtube) parseMessage(data interface{}) {
 
 	req.irc.Logger.Println("return yt info", res)
 	req.irc.sendReply(res, req)
-	req.cleanURL()
+	ctx.Stop()
 
 	return
 }
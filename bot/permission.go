@@ -0,0 +1,433 @@
+// Copyright 2016 Alex Fluter
+
+package bot
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Role is the privilege level of a user, higher roles include the lower.
+type Role int
+
+const (
+	Everyone Role = iota
+	Trusted
+	ChanOp
+	Admin
+	Owner
+)
+
+// accountPrefix marks identities matching a services account
+// instead of a hostmask, as in the $a extban
+const accountPrefix = "$a:"
+
+var (
+	ErrRoleUnknown  = errors.New("Unknown role")
+	ErrUserNotFound = errors.New("User does not exist")
+	ErrInvalidMask  = errors.New("Invalid hostmask or account")
+	ErrPermission   = errors.New("Permission denied")
+)
+
+func (r Role) String() string {
+	switch r {
+	case Everyone:
+		return "everyone"
+	case Trusted:
+		return "trusted"
+	case ChanOp:
+		return "channel operator"
+	case Admin:
+		return "admin"
+	case Owner:
+		return "owner"
+	}
+	return fmt.Sprintf("%d", r)
+}
+
+func ParseRole(s string) (Role, error) {
+	switch strings.ToLower(s) {
+	case "everyone", "all", "any":
+		return Everyone, nil
+	case "trusted":
+		return Trusted, nil
+	case "op", "chanop", "operator":
+		return ChanOp, nil
+	case "admin":
+		return Admin, nil
+	case "owner":
+		return Owner, nil
+	}
+	return Everyone, ErrRoleUnknown
+}
+
+// Identity grants a role to the users matching the mask,
+// either a nick!user@host pattern with * and ? or $a:account.
+type Identity struct {
+	Mask    string
+	Role    Role
+	Network string
+}
+
+func (id *Identity) String() string {
+	if id.Network != "" {
+		return fmt.Sprintf("%s %s on %s", id.Mask, id.Role, id.Network)
+	}
+	return fmt.Sprintf("%s %s", id.Mask, id.Role)
+}
+
+// matches checks the identity against the user, names are folded by fold.
+func (id *Identity) matches(network, source, account string, fold func(string) string) bool {
+	if id.Network != "" && !strings.EqualFold(id.Network, network) {
+		return false
+	}
+	if strings.HasPrefix(id.Mask, accountPrefix) {
+		return account != "" &&
+			fold(id.Mask[len(accountPrefix):]) == fold(account)
+	}
+	return matchMask(fold(id.Mask), fold(source))
+}
+
+// matchMask matches s against the pattern with * and ? wildcards.
+func matchMask(pattern, s string) bool {
+	var p, i int
+	var star, mark int = -1, 0
+
+	for i < len(s) {
+		if p < len(pattern) && (pattern[p] == '?' || pattern[p] == s[i]) {
+			p++
+			i++
+		} else if p < len(pattern) && pattern[p] == '*' {
+			star, mark = p, i
+			p++
+		} else if star != -1 {
+			p = star + 1
+			mark++
+			i = mark
+		} else {
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+func validMask(mask string) bool {
+	if strings.HasPrefix(mask, accountPrefix) {
+		return len(mask) > len(accountPrefix)
+	}
+	return strings.Contains(mask, "!") && strings.Contains(mask, "@")
+}
+
+const (
+	userKeyPrefix  = "user:"
+	levelKeyPrefix = "level:"
+)
+
+// Permissions keeps the identities of the users and the levels required
+// by the commands, persisted in the store if there is one.
+type Permissions struct {
+	sync.RWMutex
+	store Store
+	// from the config, never persisted
+	owners   []*Identity
+	users    map[string]*Identity
+	levels   map[string]Role
+	defaults map[string]Role
+}
+
+func NewPermissions(dbpath string, owners []string) (*Permissions, error) {
+	p := new(Permissions)
+	p.users = make(map[string]*Identity)
+	p.levels = make(map[string]Role)
+	p.defaults = make(map[string]Role)
+	for _, mask := range owners {
+		p.owners = append(p.owners, &Identity{Mask: mask, Role: Owner})
+	}
+	if dbpath == "" {
+		return p, nil
+	}
+
+	store, err := NewStoreSpace(dbpath, PERMISSION)
+	if err != nil {
+		return nil, err
+	}
+	p.store = store
+	if err = p.load(); err != nil {
+		store.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *Permissions) load() error {
+	pairs, err := p.store.List()
+	if err != nil {
+		return err
+	}
+	for _, pair := range pairs {
+		switch {
+		case strings.HasPrefix(pair.Key, userKeyPrefix):
+			id := new(Identity)
+			dec := gob.NewDecoder(bytes.NewReader(pair.Value))
+			if err = dec.Decode(id); err != nil {
+				return err
+			}
+			p.users[id.Mask] = id
+		case strings.HasPrefix(pair.Key, levelKeyPrefix):
+			role, err := ParseRole(string(pair.Value))
+			if err != nil {
+				return err
+			}
+			p.levels[pair.Key[len(levelKeyPrefix):]] = role
+		}
+	}
+	return nil
+}
+
+func (p *Permissions) Close() {
+	if p.store != nil {
+		p.store.Close()
+	}
+}
+
+// AddUser grants the role to the mask, replacing the previous one.
+func (p *Permissions) AddUser(id *Identity) error {
+	var buf bytes.Buffer
+
+	if !validMask(id.Mask) {
+		return ErrInvalidMask
+	}
+	p.Lock()
+	defer p.Unlock()
+	if p.store != nil {
+		enc := gob.NewEncoder(&buf)
+		if err := enc.Encode(id); err != nil {
+			return err
+		}
+		if err := p.store.Put(userKeyPrefix+id.Mask, buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	p.users[id.Mask] = id
+	return nil
+}
+
+// User returns the identity of the mask, the owners are not included.
+func (p *Permissions) User(mask string) *Identity {
+	p.RLock()
+	defer p.RUnlock()
+	return p.users[mask]
+}
+
+func (p *Permissions) DelUser(mask string) error {
+	p.Lock()
+	defer p.Unlock()
+	if _, ok := p.users[mask]; !ok {
+		return ErrUserNotFound
+	}
+	if p.store != nil {
+		if err := p.store.Delete(userKeyPrefix + mask); err != nil {
+			return err
+		}
+	}
+	delete(p.users, mask)
+	return nil
+}
+
+// Users returns the identities sorted by role and mask.
+func (p *Permissions) Users() []*Identity {
+	var ids []*Identity
+
+	p.RLock()
+	ids = append(ids, p.owners...)
+	for _, id := range p.users {
+		ids = append(ids, id)
+	}
+	p.RUnlock()
+	sort.Slice(ids, func(i, j int) bool {
+		if ids[i].Role != ids[j].Role {
+			return ids[i].Role > ids[j].Role
+		}
+		return ids[i].Mask < ids[j].Mask
+	})
+	return ids
+}
+
+// SetDefault sets the level of the command unless one is stored,
+// used by the modules registering commands.
+func (p *Permissions) SetDefault(cmd string, role Role) {
+	p.Lock()
+	defer p.Unlock()
+	p.defaults[strings.ToUpper(cmd)] = role
+}
+
+// SetLevel stores the level required by the command.
+func (p *Permissions) SetLevel(cmd string, role Role) error {
+	cmd = strings.ToUpper(cmd)
+	p.Lock()
+	defer p.Unlock()
+	if p.store != nil {
+		if err := p.store.Put(levelKeyPrefix+cmd, []byte(role.String())); err != nil {
+			return err
+		}
+	}
+	p.levels[cmd] = role
+	return nil
+}
+
+// Level returns the role required by the command, Everyone if not set.
+func (p *Permissions) Level(cmd string) Role {
+	cmd = strings.ToUpper(cmd)
+	p.RLock()
+	defer p.RUnlock()
+	if role, ok := p.levels[cmd]; ok {
+		return role
+	}
+	return p.defaults[cmd]
+}
+
+// Role returns the highest role of the identities matching the user.
+func (p *Permissions) Role(network, source, account string, fold func(string) string) Role {
+	var role Role
+
+	p.RLock()
+	defer p.RUnlock()
+	for _, id := range p.owners {
+		if id.matches(network, source, account, fold) {
+			return Owner
+		}
+	}
+	for _, id := range p.users {
+		if id.Role > role && id.matches(network, source, account, fold) {
+			role = id.Role
+		}
+	}
+	return role
+}
+
+// role returns the role of the user sending the request,
+// channel operators get at least ChanOp in their channel.
+func (i *Interpreter) role(req *MessageRequest) Role {
+	var role Role
+
+	role = i.irc.bot.perms.Role(i.irc.Name, req.from, i.irc.Account(req.nick),
+		i.irc.isupport.Fold)
+	if role < ChanOp && req.ischan {
+		if ch := i.irc.GetChannel(req.channel); ch != nil && ch.IsOp(req.nick) {
+			role = ChanOp
+		}
+	}
+	return role
+}
+
+// authorize checks the role of the user against the level of the command.
+func (i *Interpreter) authorize(req *MessageRequest, keyword string) error {
+	var level Role
+
+	level = i.irc.bot.perms.Level(keyword)
+	if level == Everyone || i.role(req) >= level {
+		return nil
+	}
+	return fmt.Errorf("%s, %s requires %s",
+		ErrPermission, strings.ToLower(keyword), level)
+}
+
+// AddUserCommand grants a role, only roles below the own can be granted
+// except by owners.
+// adduser <nick!user@host|$a:account> <role> [network]
+func AddUserCommand(req *MessageRequest, args string) (string, error) {
+	var id Identity
+	var err error
+
+	perms := req.irc.bot.perms
+	arr := strings.Fields(args)
+	if len(arr) < 2 || len(arr) > 3 {
+		return "adduser <nick!user@host|$a:account> <role> [network]", nil
+	}
+	id.Mask = arr[0]
+	if id.Role, err = ParseRole(arr[1]); err != nil {
+		return err.Error(), nil
+	}
+	if len(arr) == 3 {
+		id.Network = arr[2]
+	}
+	if role := req.irc.interpreter.role(req); role < Owner && id.Role >= role {
+		return fmt.Sprintf("%s, %s can not grant %s", ErrPermission, role, id.Role), nil
+	}
+	if err = perms.AddUser(&id); err != nil {
+		return err.Error(), nil
+	}
+	return fmt.Sprintf("Added %s", &id), nil
+}
+
+// DelUserCommand removes an identity, only those below the own role
+// can be removed except by owners.
+// deluser <nick!user@host|$a:account>
+func DelUserCommand(req *MessageRequest, args string) (string, error) {
+	perms := req.irc.bot.perms
+	mask := strings.TrimSpace(args)
+	if mask == "" {
+		return "deluser <nick!user@host|$a:account>", nil
+	}
+	id := perms.User(mask)
+	if id == nil {
+		return ErrUserNotFound.Error(), nil
+	}
+	if role := req.irc.interpreter.role(req); role < Owner && id.Role >= role {
+		return fmt.Sprintf("%s, %s can not remove %s", ErrPermission, role, id.Role), nil
+	}
+	if err := perms.DelUser(mask); err != nil {
+		return err.Error(), nil
+	}
+	return fmt.Sprintf("Removed %s", mask), nil
+}
+
+// users
+func UsersCommand(req *MessageRequest, args string) (string, error) {
+	var users []string
+
+	perms := req.irc.bot.perms
+	for _, id := range perms.Users() {
+		users = append(users, id.String())
+	}
+	if len(users) == 0 {
+		return "No users", nil
+	}
+	return strings.Join(users, ", "), nil
+}
+
+// LevelCommand shows or changes the level of a command, the level can only
+// be changed by those having both the current and the new one,
+// except by owners.
+// level <command> [role]
+func LevelCommand(req *MessageRequest, args string) (string, error) {
+	perms := req.irc.bot.perms
+	arr := strings.Fields(args)
+	switch len(arr) {
+	case 1:
+		return fmt.Sprintf("%s requires %s", arr[0], perms.Level(arr[0])), nil
+	case 2:
+		role, err := ParseRole(arr[1])
+		if err != nil {
+			return err.Error(), nil
+		}
+		own := req.irc.interpreter.role(req)
+		if own < Owner && (own < perms.Level(arr[0]) || own < role) {
+			return fmt.Sprintf("%s, %s can not change the level of %s",
+				ErrPermission, own, arr[0]), nil
+		}
+		if err = perms.SetLevel(arr[0], role); err != nil {
+			return err.Error(), nil
+		}
+		return fmt.Sprintf("%s now requires %s", arr[0], role), nil
+	}
+	return "level <command> [role]", nil
+}
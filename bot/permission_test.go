@@ -0,0 +1,113 @@
+package bot
+
+import (
+	"testing"
+)
+
+func TestMatchMask(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		match      bool
+	}{
+		{"*!*@host", "foo!~u@host", true},
+		{"foo!*@*", "foo!~u@host", true},
+		{"f?o!*@*.example", "foo!u@a.b.example", true},
+		{"*!*@host", "foo!~u@host2", false},
+		{"foo!*", "bar!~u@host", false},
+		{"*", "", true},
+	}
+	for _, test := range tests {
+		if matchMask(test.pattern, test.s) != test.match {
+			t.Error(test)
+		}
+	}
+}
+
+func TestPermissions(t *testing.T) {
+	ch := make(chan bool)
+	bot := newTestBot(ch)
+
+	irc := testIRC(bot, t)
+	perms := bot.perms
+	bot.perms, _ = NewPermissions("", []string{"$a:fluter"})
+	defer perms.Close()
+
+	fold := irc.isupport.Fold
+	if bot.perms.Role(irc.Name, "x!y@z", "Fluter", fold) != Owner {
+		t.Error("owner by account")
+	}
+	if err := bot.perms.AddUser(&Identity{Mask: "foo"}); err != ErrInvalidMask {
+		t.Error(err)
+	}
+	bot.perms.AddUser(&Identity{Mask: "*!*@trusted.host", Role: Trusted})
+	bot.perms.AddUser(&Identity{Mask: "*!*@admin.host", Role: Admin, Network: "other"})
+	if bot.perms.Role(irc.Name, "foo!~u@trusted.host", "", fold) != Trusted {
+		t.Error("trusted by mask")
+	}
+	if bot.perms.Role(irc.Name, "foo!~u@admin.host", "", fold) != Everyone {
+		t.Error("network")
+	}
+
+	r := testConn(irc)
+	bot.perms.SetLevel("version", Trusted)
+	irc.onCommand(command("PRIVMSG", "foo!~u@host", "#candice :?version"))
+	if s := readLine(r); s != "PRIVMSG #candice :Permission denied, version requires trusted\r\n" {
+		t.Error(s)
+	}
+	irc.onCommand(command("PRIVMSG", "foo!~u@trusted.host", "#candice :?version"))
+	if s := readLine(r); s != "PRIVMSG #candice :"+Version()+"\r\n" {
+		t.Error(s)
+	}
+	irc.onCommand(command("PRIVMSG", "foo!~u@trusted.host", "#candice :?adduser *!*@x admin"))
+	if s := readLine(r); s != "PRIVMSG #candice :Permission denied, trusted can not grant admin\r\n" {
+		t.Error(s)
+	}
+	bot.perms.AddUser(&Identity{Mask: "*!*@op.host", Role: Admin})
+	bot.perms.SetDefault("exit", Owner)
+	bot.perms.SetDefault("level", Admin)
+	irc.onCommand(command("PRIVMSG", "foo!~u@op.host", "#candice :?level exit everyone"))
+	if s := readLine(r); s != "PRIVMSG #candice :Permission denied, admin can not change the level of exit\r\n" {
+		t.Error(s)
+	}
+	irc.onCommand(command("PRIVMSG", "foo!~u@op.host", "#candice :?level version everyone"))
+	if s := readLine(r); s != "PRIVMSG #candice :version now requires everyone\r\n" {
+		t.Error(s)
+	}
+	irc.onCommand(command("PRIVMSG", "foo!~u@op.host", "#candice :?deluser *!*@admin.host"))
+	if s := readLine(r); s != "PRIVMSG #candice :Permission denied, admin can not remove admin\r\n" {
+		t.Error(s)
+	}
+	irc.onCommand(command("PRIVMSG", "foo!~u@op.host", "#candice :?deluser *!*@trusted.host"))
+	if s := readLine(r); s != "PRIVMSG #candice :Removed *!*@trusted.host\r\n" {
+		t.Error(s)
+	}
+	irc.conn = nil
+
+	delTestBot(bot, t, ch)
+}
+
+func TestAccountPrune(t *testing.T) {
+	ch := make(chan bool)
+	bot := newTestBot(ch)
+	irc := testIRC(bot, t)
+
+	irc.JoinChannel("#candice").add("foo")
+	irc.JoinChannel("#other").add("foo")
+	irc.setAccount("foo", "fluter")
+
+	irc.onCommand(command("PART", "foo!~u@host", "#candice"))
+	if irc.Account("foo") != "fluter" {
+		t.Error("account of a nick still in sight dropped")
+	}
+	irc.onCommand(command("KICK", "op!~u@host", "#other foo :bye"))
+	if irc.Account("foo") != "" {
+		t.Error("account kept after leaving the last shared channel")
+	}
+
+	// WHOIS of a nick not in sight is not remembered
+	irc.onCommand(command("330", "server", G+" bar fluter :is logged in as"))
+	if irc.Account("bar") != "" {
+		t.Error("account of a nick out of sight kept")
+	}
+	delTestBot(bot, t, ch)
+}
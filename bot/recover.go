@@ -0,0 +1,137 @@
+// Copyright 2016 Alex Fluter
+
+package bot
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// crashes counts the panics recovered from the handlers and the modules
+// owning them, modules are disabled after too many.
+type crashes struct {
+	sync.Mutex
+	handlers map[string]int
+	modules  map[string]int
+	disabled map[string]bool
+}
+
+func newCrashes() *crashes {
+	c := new(crashes)
+	c.handlers = make(map[string]int)
+	c.modules = make(map[string]int)
+	c.disabled = make(map[string]bool)
+	return c
+}
+
+// reset forgets the crashes of the module when it is enabled again.
+func (c *crashes) reset(mod string) {
+	c.Lock()
+	defer c.Unlock()
+	delete(c.modules, mod)
+	delete(c.disabled, mod)
+}
+
+// funcName returns the name of the function like bot.(*Youtube).handleMessage.
+func funcName(f interface{}) string {
+	var name string
+
+	fn := runtime.FuncForPC(reflect.ValueOf(f).Pointer())
+	if fn == nil {
+		return "unknown"
+	}
+	name = fn.Name()
+	if i := strings.LastIndexByte(name, '/'); i != -1 {
+		name = name[i+1:]
+	}
+	return strings.TrimSuffix(name, "-fm")
+}
+
+func (bot *Bot) isDisabled(mod Module) bool {
+	bot.crashes.Lock()
+	defer bot.crashes.Unlock()
+	return bot.crashes.disabled[fmt.Sprint(mod)]
+}
+
+// recoverPanic is deferred by the callers of handlers and commands,
+// the panic is logged and counted instead of killing the bot.
+// mod is the addon module owning the handler, nil for the core.
+func (bot *Bot) recoverPanic(name string, mod Module) {
+	if r := recover(); r != nil {
+		bot.onPanic(name, mod, r)
+	}
+}
+
+func (bot *Bot) onPanic(name string, mod Module, r interface{}) {
+	var modName string
+	var disable bool
+
+	bot.Logger.Printf("Panic in %s: %v\n%s", name, r, debug.Stack())
+
+	bot.crashes.Lock()
+	bot.crashes.handlers[name]++
+	if mod != nil {
+		modName = fmt.Sprint(mod)
+		bot.crashes.modules[modName]++
+		if bot.crashes.modules[modName] >= bot.config.GetMaxCrashes() &&
+			!bot.crashes.disabled[modName] {
+			bot.crashes.disabled[modName] = true
+			disable = true
+		}
+	}
+	bot.crashes.Unlock()
+
+	if disable {
+		bot.Logger.Printf("Module %s disabled after %d crashes",
+			modName, bot.config.GetMaxCrashes())
+		// the handler may be holding what Stop waits for
+		go bot.stopModule(mod)
+	}
+}
+
+// callHandler invokes the event handler unless its module is disabled.
+func (bot *Bot) callHandler(s *subscriber, ctx *EventContext) {
+	var arg reflect.Value
+
+	if mod := s.owner; mod != nil {
+		if bot.isDisabled(mod) {
+			return
+		}
+		// disabled in the channel or network of the event
+		if irc, target := eventTarget(ctx.Event); irc != nil &&
+			!irc.ModuleEnabled(target, fmt.Sprint(mod)) {
+			return
+		}
+	}
+	arg = reflect.ValueOf(ctx.Event.data)
+	if !arg.IsValid() {
+		arg = reflect.Zero(s.typ)
+	} else if !arg.Type().AssignableTo(s.typ) {
+		bot.Logger.Printf("%s takes %s, %s data of %s dropped",
+			s.name, s.typ, arg.Type(), ctx.Event.evt)
+		return
+	}
+	defer bot.recoverPanic(s.name, s.owner)
+	s.fn.Call([]reflect.Value{reflect.ValueOf(ctx), arg})
+}
+
+// Crashes returns the number of panics of each handler.
+func (bot *Bot) Crashes() string {
+	var s []string
+
+	bot.crashes.Lock()
+	for name, n := range bot.crashes.handlers {
+		s = append(s, fmt.Sprintf("%s: %d", name, n))
+	}
+	for name := range bot.crashes.disabled {
+		s = append(s, fmt.Sprintf("%s: disabled", name))
+	}
+	bot.crashes.Unlock()
+	sort.Strings(s)
+	return strings.Join(s, ", ")
+}
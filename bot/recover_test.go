@@ -0,0 +1,82 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+)
+
+type crashModule struct {
+	BaseModule
+	stopped chan bool
+}
+
+func (m *crashModule) Init() error    { return nil }
+func (m *crashModule) Start() error   { return nil }
+func (m *crashModule) Stop() error    { m.stopped <- true; return nil }
+func (m *crashModule) Status() string { return "" }
+func (m *crashModule) Run()           {}
+func (m *crashModule) String() string { return "crashModule" }
+
+func (m *crashModule) handle(ctx *EventContext, data interface{}) {
+	panic(data)
+}
+
+// the closures are owned by the module too
+func (m *crashModule) Commands() map[string]Command {
+	return map[string]Command{"boom": func(*MessageRequest, string) (string, error) {
+		panic("boom")
+	}}
+}
+
+func (m *crashModule) Handlers() []Handler {
+	return []Handler{OnEvent(255, m.handle, Normal)}
+}
+
+func TestRecover(t *testing.T) {
+	ch := make(chan bool)
+	bot := newTestBot(ch)
+
+	irc := testIRC(bot, t)
+
+	mod := &crashModule{stopped: make(chan bool, 1)}
+	bot.addons = append(bot.addons, mod)
+	if name := funcName(mod.handle); name != "bot.(*crashModule).handle" {
+		t.Error(name)
+	}
+	if err := bot.startModule(mod); err != nil {
+		t.Fatal(err)
+	}
+	req := &MessageRequest{irc: irc, nick: "foo"}
+	if _, err := irc.interpreter.invoke("boom", irc.interpreter.GetCommand("boom"), req, ""); err == nil {
+		t.Error("no error")
+	}
+	const evt = 255
+	for i := 1; i < bot.config.GetMaxCrashes(); i++ {
+		bot.handleEvent(NewEvent(evt, "boom"))
+	}
+	<-mod.stopped
+	if !bot.isDisabled(mod) {
+		t.Error("module not disabled")
+	}
+	// handlers of the disabled module are skipped
+	bot.handleEvent(NewEvent(evt, "boom"))
+
+	// a deliberate panic in the IRC handlers
+	irc.onCommand(command("INVITE", "foo!~u@host", "notme #candice"))
+	crashes := bot.Crashes()
+	if !strings.Contains(crashes, "bot.(*crashModule).handle: 2") ||
+		!strings.Contains(crashes, "crashModule: disabled") ||
+		!strings.Contains(crashes, "IRC(Localhost) INVITE: 1") {
+		t.Error(crashes)
+	}
+
+	// core commands are not owned by a module
+	irc.interpreter.RegisterCommand("crash", func(*MessageRequest, string) (string, error) {
+		panic("crash")
+	})
+	if _, err := irc.interpreter.invoke("crash", irc.interpreter.GetCommand("crash"), req, ""); err == nil {
+		t.Error("no error")
+	}
+
+	delTestBot(bot, t, ch)
+}
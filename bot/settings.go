@@ -0,0 +1,244 @@
+// Copyright 2016 Alex Fluter
+
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// settingEnabled is known to every module, a module disabled in a channel
+// gets no events and runs no commands there.
+const settingEnabled = "enabled"
+
+var ErrInvalidSetting = errors.New("Invalid setting")
+
+// Settings are the module settings of a channel or network,
+// keyed by the lower case module name then the setting name.
+// The channel settings are kept apart from Channels, which are joined,
+// keyed by the channel name folded with the server casemapping.
+type Settings map[string]map[string]string
+
+// settingsLock guards the settings of all the configs,
+// they are changed by the set command while the bot is running.
+var settingsLock sync.RWMutex
+
+func (s Settings) get(module, key string) (string, bool) {
+	v, ok := s[strings.ToLower(module)][strings.ToLower(key)]
+	return v, ok
+}
+
+// set changes the setting, an empty value removes it.
+func (s Settings) set(module, key, value string) {
+	module, key = strings.ToLower(module), strings.ToLower(key)
+	if value == "" {
+		delete(s[module], key)
+		if len(s[module]) == 0 {
+			delete(s, module)
+		}
+		return
+	}
+	if s[module] == nil {
+		s[module] = make(map[string]string)
+	}
+	s[module][key] = value
+}
+
+// channelKey returns the key of the channel in ChannelSettings,
+// empty for the network.
+func (irc *IRC) channelKey(channel string) string {
+	if channel == "" {
+		return ""
+	}
+	return irc.isupport.Fold(channel)
+}
+
+// Setting returns the setting of the module in channel,
+// the network setting is used if the channel has none.
+func (irc *IRC) Setting(channel, module, key string) (string, bool) {
+	config := irc.config
+	settingsLock.RLock()
+	defer settingsLock.RUnlock()
+	if chn := irc.channelKey(channel); chn != "" {
+		if v, ok := config.ChannelSettings[chn].get(module, key); ok {
+			return v, true
+		}
+	}
+	return config.Settings.get(module, key)
+}
+
+func (irc *IRC) GetSetting(channel, module, key, def string) string {
+	if v, ok := irc.Setting(channel, module, key); ok {
+		return v
+	}
+	return def
+}
+
+func (irc *IRC) GetSettingBool(channel, module, key string, def bool) bool {
+	if v, ok := irc.Setting(channel, module, key); ok {
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+	return def
+}
+
+// ModuleEnabled checks if the module is enabled in channel,
+// or in the network if channel is empty.
+func (irc *IRC) ModuleEnabled(channel, module string) bool {
+	return irc.GetSettingBool(channel, module, settingEnabled, true)
+}
+
+// SetSetting changes the setting of the module in channel,
+// or the network if channel is empty.
+func (irc *IRC) SetSetting(channel, module, key, value string) error {
+	var settings Settings
+
+	if key == settingEnabled && value != "" {
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("%s, %s is true or false", ErrInvalidSetting, key)
+		}
+	}
+	config := irc.config
+	settingsLock.Lock()
+	defer settingsLock.Unlock()
+	if chn := irc.channelKey(channel); chn == "" {
+		if config.Settings == nil {
+			config.Settings = make(Settings)
+		}
+		settings = config.Settings
+	} else {
+		if config.ChannelSettings == nil {
+			config.ChannelSettings = make(map[string]Settings)
+		}
+		if config.ChannelSettings[chn] == nil {
+			config.ChannelSettings[chn] = make(Settings)
+		}
+		settings = config.ChannelSettings[chn]
+		defer func() {
+			if len(settings) == 0 {
+				delete(config.ChannelSettings, chn)
+			}
+		}()
+	}
+	settings.set(module, key, value)
+	return nil
+}
+
+// ModuleSettings returns the settings of the module in channel as key=value,
+// including those of the network.
+func (irc *IRC) ModuleSettings(channel, module string) []string {
+	var all = make(map[string]string)
+	var s []string
+
+	config := irc.config
+	module = strings.ToLower(module)
+	settingsLock.RLock()
+	for k, v := range config.Settings[module] {
+		all[k] = v
+	}
+	if chn := irc.channelKey(channel); chn != "" {
+		for k, v := range config.ChannelSettings[chn][module] {
+			all[k] = v
+		}
+	}
+	settingsLock.RUnlock()
+	for k, v := range all {
+		s = append(s, k+"="+v)
+	}
+	sort.Strings(s)
+	return s
+}
+
+// settingTarget parses the optional channel or * for the network before
+// the arguments, the current channel is the default. Only admins can
+// change other channels and the network.
+func settingTarget(req *MessageRequest, arr []string, change bool) (string, []string, error) {
+	var target string
+
+	if len(arr) > 0 && (arr[0] == "*" || req.irc.isupport.IsChannel(arr[0])) {
+		target, arr = arr[0], arr[1:]
+	} else if req.ischan {
+		target = req.channel
+	} else {
+		target = "*"
+	}
+	if change && !req.irc.isupport.Equal(target, req.channel) &&
+		req.irc.interpreter.role(req) < Admin {
+		return "", nil, fmt.Errorf("%s, %s requires %s", ErrPermission, target, Admin)
+	}
+	if target == "*" {
+		target = ""
+	}
+	return target, arr, nil
+}
+
+func targetName(target string) string {
+	if target == "" {
+		return "the network"
+	}
+	return target
+}
+
+// set [#channel|*] <module> <key> [value]
+func SetSettingCommand(req *MessageRequest, args string) (string, error) {
+	const usage = "set [#channel|*] <module> <key> [value]"
+
+	target, arr, err := settingTarget(req, strings.Fields(args), true)
+	if err != nil {
+		return err.Error(), nil
+	}
+	if len(arr) < 2 {
+		return usage, nil
+	}
+	mod := req.irc.bot.GetModule(arr[0])
+	if mod == nil {
+		return fmt.Sprintf("%s: %s", ErrModuleNotFound, arr[0]), nil
+	}
+	module, key := fmt.Sprint(mod), strings.ToLower(arr[1])
+	value := strings.Join(arr[2:], " ")
+	if err = req.irc.SetSetting(target, module, key, value); err != nil {
+		return err.Error(), nil
+	}
+	if value == "" {
+		return fmt.Sprintf("%s %s unset in %s", module, key, targetName(target)), nil
+	}
+	return fmt.Sprintf("%s %s set to %s in %s", module, key, value, targetName(target)), nil
+}
+
+// get [#channel|*] <module> [key]
+func GetSettingCommand(req *MessageRequest, args string) (string, error) {
+	target, arr, err := settingTarget(req, strings.Fields(args), false)
+	if err != nil {
+		return err.Error(), nil
+	}
+	if len(arr) < 1 {
+		return "get [#channel|*] <module> [key]", nil
+	}
+	mod := req.irc.bot.GetModule(arr[0])
+	if mod == nil {
+		return fmt.Sprintf("%s: %s", ErrModuleNotFound, arr[0]), nil
+	}
+	module := fmt.Sprint(mod)
+	if len(arr) > 1 {
+		v, ok := req.irc.Setting(target, module, arr[1])
+		if !ok {
+			return fmt.Sprintf("%s %s is not set in %s", module, arr[1], targetName(target)), nil
+		}
+		return fmt.Sprintf("%s %s is %s in %s", module, arr[1], v, targetName(target)), nil
+	}
+	settings := req.irc.ModuleSettings(target, module)
+	state := "enabled"
+	if !req.irc.ModuleEnabled(target, module) {
+		state = "disabled"
+	}
+	if len(settings) == 0 {
+		return fmt.Sprintf("%s is %s in %s", module, state, targetName(target)), nil
+	}
+	return fmt.Sprintf("%s is %s in %s: %s", module, state, targetName(target),
+		strings.Join(settings, ", ")), nil
+}
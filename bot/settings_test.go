@@ -0,0 +1,82 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSettings(t *testing.T) {
+	config := &IRCConfig{
+		Channels: []*ChannelConfig{
+			&ChannelConfig{Name: "#c", Lang: "C++", Repaste: true},
+		},
+	}
+	irc := &IRC{config: config, isupport: NewISupport()}
+
+	if !irc.ModuleEnabled("#c", "Youtube") {
+		t.Error("enabled by default")
+	}
+	irc.SetSetting("", "Youtube", "enabled", "false")
+	irc.SetSetting("#c", "youtube", "Enabled", "true")
+	if irc.ModuleEnabled("", "Youtube") || irc.ModuleEnabled("#other", "Youtube") {
+		t.Error("network setting ignored")
+	}
+	if !irc.ModuleEnabled("#C", "Youtube") {
+		t.Error("channel setting ignored")
+	}
+	if err := irc.SetSetting("#c", "Youtube", "enabled", "maybe"); err == nil {
+		t.Error("invalid enabled accepted")
+	}
+
+	irc.SetSetting("#C", "CodePasteChecker", "lang", "Go")
+	if irc.GetSetting("#c", "CodePasteChecker", "lang", "") != "Go" {
+		t.Error("channel case not folded")
+	}
+	irc.SetSetting("#c", "CodePasteChecker", "lang", "")
+	if _, ok := irc.Setting("#c", "CodePasteChecker", "lang"); ok {
+		t.Error("setting not removed")
+	}
+
+	// channels are not joined by setting them
+	irc.SetSetting("#new", "Cjeopardy", "moderator", "bob")
+	if irc.GetSetting("#new", "Cjeopardy", "moderator", "") != "bob" {
+		t.Error(config.ChannelSettings)
+	}
+	if len(config.Channels) != 1 {
+		t.Error(config.Channels)
+	}
+}
+
+func TestSettingCommands(t *testing.T) {
+	ch := make(chan bool)
+	bot := newTestBot(ch)
+	irc := testIRC(bot, t)
+
+	req := &MessageRequest{irc: irc, ischan: true, channel: "#candice",
+		from: "foo!~u@host", nick: "foo"}
+	res, _ := SetSettingCommand(req, "lagchecker enabled false")
+	if res != "LagChecker enabled set to false in #candice" {
+		t.Error(res)
+	}
+	res, _ = GetSettingCommand(req, "LagChecker")
+	if res != "LagChecker is disabled in #candice: enabled=false" {
+		t.Error(res)
+	}
+	if _, err := irc.interpreter.invoke("lagcheck",
+		irc.interpreter.GetCommand("lagcheck"), req, ""); err == nil ||
+		!strings.Contains(err.Error(), "disabled in #candice") {
+		t.Error(err)
+	}
+
+	// other channels and the network are for admins
+	res, _ = SetSettingCommand(req, "* LagChecker enabled false")
+	if !strings.HasPrefix(res, ErrPermission.Error()) {
+		t.Error(res)
+	}
+	res, _ = SetSettingCommand(req, "nothing enabled false")
+	if !strings.HasPrefix(res, ErrModuleNotFound.Error()) {
+		t.Error(res)
+	}
+
+	delTestBot(bot, t, ch)
+}
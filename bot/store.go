@@ -4,6 +4,7 @@ package bot
 
 import (
 	"errors"
+	"sync"
 
 	"github.com/boltdb/bolt"
 )
@@ -13,12 +14,14 @@ type StoreSpace int
 const (
 	ROOT StoreSpace = iota
 	FACTOID
+	PERMISSION
 )
 
 var (
 	SpaceNames = map[StoreSpace]string{
-		ROOT:    "ROOT",
-		FACTOID: "FACTOID",
+		ROOT:       "ROOT",
+		FACTOID:    "FACTOID",
+		PERMISSION: "PERMISSION",
 	}
 )
 
@@ -44,25 +47,67 @@ type Store interface {
 
 type BoltStore struct {
 	db        *bolt.DB
+	path      string
 	space     StoreSpace
 	spacename []byte
 }
 
-func NewStore(path string) (Store, error) {
-	return NewStoreSpace(path, ROOT)
+// the spaces of a database share one handle, bolt locks the file
+// exclusively so it can be opened only once
+type sharedDB struct {
+	db   *bolt.DB
+	refs int
 }
 
-func NewStoreSpace(path string, space StoreSpace) (Store, error) {
+var (
+	dbs    = make(map[string]*sharedDB)
+	dbLock sync.Mutex
+)
+
+func openDB(path string) (*bolt.DB, error) {
+	dbLock.Lock()
+	defer dbLock.Unlock()
+	if s, ok := dbs[path]; ok {
+		s.refs++
+		return s.db, nil
+	}
 	db, err := bolt.Open(path, 0644, nil)
 	if err != nil {
 		return nil, err
 	}
+	dbs[path] = &sharedDB{db, 1}
+	return db, nil
+}
+
+func closeDB(path string) {
+	dbLock.Lock()
+	defer dbLock.Unlock()
+	s, ok := dbs[path]
+	if !ok {
+		return
+	}
+	s.refs--
+	if s.refs == 0 {
+		s.db.Close()
+		delete(dbs, path)
+	}
+}
+
+func NewStore(path string) (Store, error) {
+	return NewStoreSpace(path, ROOT)
+}
 
+func NewStoreSpace(path string, space StoreSpace) (Store, error) {
 	name, ok := SpaceNames[space]
 	if !ok {
 		return nil, ErrSpaceNotFound
 	}
 
+	db, err := openDB(path)
+	if err != nil {
+		return nil, err
+	}
+
 	err = db.Update(func(tx *bolt.Tx) error {
 		_, err := tx.CreateBucketIfNotExists([]byte(name))
 		if err != nil {
@@ -71,10 +116,11 @@ func NewStoreSpace(path string, space StoreSpace) (Store, error) {
 		return nil
 	})
 	if err != nil {
-		db.Close()
+		closeDB(path)
 		return nil, err
 	}
 	return &BoltStore{db: db,
+		path:      path,
 		space:     space,
 		spacename: []byte(name)}, nil
 }
@@ -172,7 +218,7 @@ func (b *BoltStore) List() ([]*Pair, error) {
 }
 
 func (b *BoltStore) Close() {
-	b.db.Close()
+	closeDB(b.path)
 }
 
 func dup(src []byte) []byte {
@@ -7,11 +7,26 @@ import (
 	"fmt"
 	"runtime/debug"
 	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 )
 
+// IsChannel checks name against the default channel types,
+// use IRC.IsChannel for the types advertised by the server.
 func IsChannel(name string) bool {
-	return name[0] == '#'
+	return name != "" && strings.IndexByte(DefaultChanTypes, name[0]) != -1
+}
+
+// truncate cuts s to at most n bytes without splitting a rune.
+func truncate(s string, n int) string {
+	if n < 0 || len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
 }
 
 func IsNick(name string) bool {
@@ -58,9 +73,11 @@ func unixTimeStr(ss string) string {
 
 // TODO: cert
 func dumpCert(cert *x509.Certificate) string {
-	return fmt.Sprintf("subject `%s', issue `%s'",
+	return fmt.Sprintf("subject `%s', issue `%s', fingerprint %s, expires %s",
 		cert.Subject,
-		cert.Issuer)
+		cert.Issuer,
+		certFingerprint(cert),
+		cert.NotAfter.Format(time.RFC3339))
 }
 
 func dateTime(t time.Time) string {
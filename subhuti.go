@@ -5,7 +5,9 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 
 	"github.com/fluter01/subhuti/bot"
 )
@@ -32,7 +34,51 @@ func usage() {
 	flag.PrintDefaults()
 }
 
+// ctl sends bot commands to the control socket of a running bot,
+// from the arguments or one per line from stdin.
+func ctl(args []string) {
+	var socket string
+	var in io.Reader
+
+	fs := flag.NewFlagSet("ctl", flag.ExitOnError)
+	fs.StringVar(&cfg, "config", "bot.cfg", "bot configuration file")
+	fs.StringVar(&socket, "socket", "", "control socket, from config if not set")
+	fs.Usage = func() {
+		fmt.Printf("Usage: %s ctl [options] [command...]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if socket == "" {
+		config := bot.NewConfig()
+		if err := config.Load(cfg); err != nil {
+			fmt.Println("config error")
+			os.Exit(1)
+		}
+		socket = config.ControlSocket
+	}
+	if socket == "" {
+		fmt.Println("control socket is not configured")
+		os.Exit(1)
+	}
+
+	if fs.NArg() > 0 {
+		in = strings.NewReader(strings.Join(fs.Args(), " "))
+	} else {
+		in = os.Stdin
+	}
+	if err := bot.RunControl(socket, in, os.Stdout); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "ctl" {
+		ctl(os.Args[2:])
+		return
+	}
+
 	flag.StringVar(&cfg, "config", "bot.cfg", "bot configuration file")
 	flag.BoolVar(&help, "help", false, "show help message")
 	flag.BoolVar(&noproxy, "noproxy", false, "do not use proxy")